@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+	"github.com/Softorize/yoy/internal/yahoo"
+)
+
+// MailAttachmentsCmd lists or saves the attachments of a message.
+type MailAttachmentsCmd struct {
+	UID   uint32 `arg:"" help:"Message UID."`
+	Save  string `help:"Save attachments to this directory." type:"path" placeholder:"DIR"`
+	Name  string `help:"Only attachments whose filename matches this glob." placeholder:"GLOB"`
+	Index int    `help:"Only the attachment with this index."`
+}
+
+// Run lists or saves attachments.
+func (c *MailAttachmentsCmd) Run(ctx *Context) error {
+	client, err := ctx.IMAPClient()
+	if err != nil {
+		return err
+	}
+
+	attachments, err := client.ListAttachments(ctx.Folder, c.UID)
+	if err != nil {
+		return err
+	}
+
+	attachments, err = c.filter(attachments)
+	if err != nil {
+		return err
+	}
+
+	if len(attachments) == 0 {
+		fmt.Println("No attachments found.")
+		return nil
+	}
+
+	if c.Save == "" {
+		return ctx.Formatter().FormatAttachments(os.Stdout, attachments)
+	}
+
+	if err := os.MkdirAll(c.Save, 0755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+
+	for i := range attachments {
+		path, err := c.saveAttachment(client, ctx.Folder, &attachments[i])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Saved %s\n", path)
+	}
+
+	return nil
+}
+
+// filter applies the --index and --name selectors.
+func (c *MailAttachmentsCmd) filter(attachments []yahoo.Attachment) ([]yahoo.Attachment, error) {
+	if c.Name != "" {
+		if _, err := filepath.Match(c.Name, ""); err != nil {
+			return nil, yoyerrors.Wrap("invalid --name pattern", err, yoyerrors.ExitInvalidInput)
+		}
+	}
+
+	var selected []yahoo.Attachment
+	for _, a := range attachments {
+		if c.Index != 0 && a.Index != c.Index {
+			continue
+		}
+		if c.Name != "" {
+			if ok, _ := filepath.Match(strings.ToLower(c.Name), strings.ToLower(a.Filename)); !ok {
+				continue
+			}
+		}
+		selected = append(selected, a)
+	}
+	return selected, nil
+}
+
+// saveAttachment downloads one attachment into the --save directory and
+// returns the path it was written to. Existing files are never overwritten.
+func (c *MailAttachmentsCmd) saveAttachment(client *yahoo.IMAPClient, folder string, att *yahoo.Attachment) (string, error) {
+	// Only the base name is used so a crafted filename cannot escape DIR.
+	name := filepath.Base(filepath.Clean("/" + att.Filename))
+	if name == "/" || name == "." {
+		name = fmt.Sprintf("attachment-%d", att.Index)
+	}
+
+	f, path, err := createUnique(filepath.Join(c.Save, name))
+	if err != nil {
+		return "", fmt.Errorf("creating file: %w", err)
+	}
+
+	if err := client.FetchAttachment(folder, c.UID, att, f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("writing %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// createUnique creates path, or "name (N).ext" if path already exists.
+func createUnique(path string) (*os.File, string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return f, candidate, nil
+		}
+		if !os.IsExist(err) {
+			return nil, "", err
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
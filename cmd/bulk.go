@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/emersion/go-imap/v2"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+	"github.com/Softorize/yoy/internal/yahoo"
+)
+
+// messageSelection selects the messages a bulk command acts on, by UID list
+// or range, by search query, or both.
+type messageSelection struct {
+	UIDs  string `arg:"" optional:"" name:"uids" help:"Message UIDs, e.g. 101,105,200:250."`
+	Query string `help:"Select the messages matching a search query." placeholder:"QUERY"`
+}
+
+// resolve returns the selected messages that exist in folder and their count.
+// When nothing matches it prints a notice and returns a zero count.
+func (s *messageSelection) resolve(client *yahoo.IMAPClient, folder string) (imap.UIDSet, int, error) {
+	if s.UIDs == "" && s.Query == "" {
+		return nil, 0, yoyerrors.New("no messages selected", yoyerrors.ExitInvalidInput).
+			WithHint("Pass UIDs such as 101,105,200:250 or --query.")
+	}
+
+	var uids imap.UIDSet
+	if s.UIDs != "" {
+		var err error
+		if uids, err = yahoo.ParseUIDSet(s.UIDs); err != nil {
+			return nil, 0, yoyerrors.Wrap("invalid UIDs", err, yoyerrors.ExitInvalidInput)
+		}
+	}
+
+	found, n, err := client.FindUIDs(folder, uids, s.Query)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n == 0 {
+		fmt.Println("No messages found.")
+	}
+	return found, n, nil
+}
+
+// describeMessages names the affected messages in a summary line, e.g.
+// "Message 101" or "3 messages".
+func describeMessages(uids imap.UIDSet, n int) string {
+	if n == 1 {
+		return "Message " + uids.String()
+	}
+	return fmt.Sprintf("%d messages", n)
+}
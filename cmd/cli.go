@@ -3,6 +3,8 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/Softorize/yoy/internal/config"
 	"github.com/Softorize/yoy/internal/output"
@@ -15,6 +17,8 @@ type CLI struct {
 	Folder  string `help:"Mail folder." env:"YOY_FOLDER" short:"f" default:"INBOX"`
 	JSON    bool   `help:"Output as JSON." env:"YOY_JSON"`
 	Plain   bool   `help:"Output as plain TSV (no colors/borders)." env:"YOY_PLAIN"`
+	Output  string `help:"Output format: table, json, plain, csv, ndjson or yaml." short:"o" placeholder:"FORMAT"`
+	Format  string `help:"Format each item with a Go template, e.g. '{{.UID}}\t{{.Subject}}'." placeholder:"TEMPLATE"`
 	Color   string `help:"Color mode: auto, always, never." default:"auto" enum:"auto,always,never"`
 	Verbose bool   `help:"Enable verbose output." short:"v"`
 
@@ -22,6 +26,8 @@ type CLI struct {
 	Auth       AuthCmd       `cmd:"" help:"Manage authentication."`
 	Mail       MailCmd       `cmd:"" help:"Mail operations."`
 	Folders    FoldersCmd    `cmd:"" help:"Manage mail folders."`
+	Draft      DraftCmd      `cmd:"" help:"Manage drafts."`
+	Outbox     OutboxCmd     `cmd:"" help:"Manage messages queued with send --at."`
 	Config     ConfigCmd     `cmd:"" help:"Manage configuration."`
 	Version    VersionCmd    `cmd:"" help:"Print version information."`
 	Completion CompletionCmd `cmd:"" help:"Generate shell completions."`
@@ -32,11 +38,21 @@ type CLI struct {
 	Search MailSearchCmd `cmd:"" help:"Search messages (alias for mail search)." hidden:""`
 }
 
+// Validate checks the global flags before a command runs.
+func (c *CLI) Validate() error {
+	if c.Output != "" && !slices.Contains(config.OutputFormats, c.Output) {
+		return fmt.Errorf("--output must be one of %s but got %q", strings.Join(config.OutputFormats, ", "), c.Output)
+	}
+	return nil
+}
+
 // Context holds shared state for all commands.
 type Context struct {
 	Config  *config.Config
 	JSON    bool
 	Plain   bool
+	Output  string
+	Format  string
 	Verbose bool
 	Color   bool
 	Folder  string
@@ -85,11 +101,18 @@ func (c *Context) Formatter() output.Formatter {
 	if c.formatter != nil {
 		return c.formatter
 	}
-	format := "table"
-	if c.JSON {
+	if c.Format != "" {
+		c.formatter = output.NewTemplateFormatter(c.Format)
+		return c.formatter
+	}
+	format := c.Output
+	switch {
+	case c.JSON:
 		format = "json"
-	} else if c.Plain {
+	case c.Plain:
 		format = "plain"
+	case format == "" && c.Config != nil:
+		format = c.Config.OutputFormat
 	}
 	c.formatter = output.NewFormatter(format, c.Color)
 	return c.formatter
@@ -99,6 +122,7 @@ func (c *Context) Formatter() output.Formatter {
 func (c *Context) Close() {
 	if c.imapClient != nil {
 		c.imapClient.Close()
+		c.imapClient = nil
 	}
 }
 
@@ -109,6 +133,8 @@ func NewContext(cli *CLI, cfg *config.Config) *Context {
 		Config:  cfg,
 		JSON:    cli.JSON,
 		Plain:   cli.Plain,
+		Output:  cli.Output,
+		Format:  cli.Format,
 		Verbose: cli.Verbose,
 		Color:   colorEnabled,
 		Folder:  cli.Folder,
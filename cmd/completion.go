@@ -27,7 +27,7 @@ _yoy_completions() {
     cur="${COMP_WORDS[COMP_CWORD]}"
     prev="${COMP_WORDS[COMP_CWORD-1]}"
 
-    commands="auth mail folders send ls search config version completion"
+    commands="auth mail folders draft outbox send ls search config version completion"
 
     if [[ ${COMP_CWORD} -eq 1 ]]; then
         COMPREPLY=($(compgen -W "${commands}" -- "${cur}"))
@@ -39,11 +39,17 @@ _yoy_completions() {
             COMPREPLY=($(compgen -W "login logout status" -- "${cur}"))
             ;;
         mail)
-            COMPREPLY=($(compgen -W "list search read send reply forward delete move star unstar mark-read mark-unread" -- "${cur}"))
+            COMPREPLY=($(compgen -W "list search read thread raw attachments send reply forward delete move star unstar mark-read mark-unread" -- "${cur}"))
             ;;
         folders)
             COMPREPLY=($(compgen -W "list create delete" -- "${cur}"))
             ;;
+        draft)
+            COMPREPLY=($(compgen -W "save list edit send" -- "${cur}"))
+            ;;
+        outbox)
+            COMPREPLY=($(compgen -W "list cancel flush run" -- "${cur}"))
+            ;;
         config)
             COMPREPLY=($(compgen -W "get set list path" -- "${cur}"))
             ;;
@@ -63,6 +69,8 @@ _yoy() {
         'auth:Manage authentication'
         'mail:Mail operations'
         'folders:Manage mail folders'
+        'draft:Manage drafts'
+        'outbox:Manage scheduled messages'
         'send:Send an email'
         'ls:List messages'
         'search:Search messages'
@@ -94,6 +102,8 @@ const fishCompletion = `# yoy fish completion
 complete -c yoy -n '__fish_use_subcommand' -a 'auth' -d 'Manage authentication'
 complete -c yoy -n '__fish_use_subcommand' -a 'mail' -d 'Mail operations'
 complete -c yoy -n '__fish_use_subcommand' -a 'folders' -d 'Manage mail folders'
+complete -c yoy -n '__fish_use_subcommand' -a 'draft' -d 'Manage drafts'
+complete -c yoy -n '__fish_use_subcommand' -a 'outbox' -d 'Manage scheduled messages'
 complete -c yoy -n '__fish_use_subcommand' -a 'send' -d 'Send an email'
 complete -c yoy -n '__fish_use_subcommand' -a 'ls' -d 'List messages'
 complete -c yoy -n '__fish_use_subcommand' -a 'search' -d 'Search messages'
@@ -110,6 +120,9 @@ complete -c yoy -n '__fish_seen_subcommand_from auth' -a 'status' -d 'Show auth
 complete -c yoy -n '__fish_seen_subcommand_from mail' -a 'list' -d 'List messages'
 complete -c yoy -n '__fish_seen_subcommand_from mail' -a 'search' -d 'Search messages'
 complete -c yoy -n '__fish_seen_subcommand_from mail' -a 'read' -d 'Read a message'
+complete -c yoy -n '__fish_seen_subcommand_from mail' -a 'thread' -d 'Show a conversation'
+complete -c yoy -n '__fish_seen_subcommand_from mail' -a 'raw' -d 'Print raw message source'
+complete -c yoy -n '__fish_seen_subcommand_from mail' -a 'attachments' -d 'List or save attachments'
 complete -c yoy -n '__fish_seen_subcommand_from mail' -a 'send' -d 'Send an email'
 complete -c yoy -n '__fish_seen_subcommand_from mail' -a 'reply' -d 'Reply to a message'
 complete -c yoy -n '__fish_seen_subcommand_from mail' -a 'forward' -d 'Forward a message'
@@ -125,6 +138,18 @@ complete -c yoy -n '__fish_seen_subcommand_from folders' -a 'list' -d 'List fold
 complete -c yoy -n '__fish_seen_subcommand_from folders' -a 'create' -d 'Create a folder'
 complete -c yoy -n '__fish_seen_subcommand_from folders' -a 'delete' -d 'Delete a folder'
 
+# draft subcommands
+complete -c yoy -n '__fish_seen_subcommand_from draft' -a 'save' -d 'Save a new draft'
+complete -c yoy -n '__fish_seen_subcommand_from draft' -a 'list' -d 'List drafts'
+complete -c yoy -n '__fish_seen_subcommand_from draft' -a 'edit' -d 'Edit a draft'
+complete -c yoy -n '__fish_seen_subcommand_from draft' -a 'send' -d 'Send a draft'
+
+# outbox subcommands
+complete -c yoy -n '__fish_seen_subcommand_from outbox' -a 'list' -d 'List queued messages'
+complete -c yoy -n '__fish_seen_subcommand_from outbox' -a 'cancel' -d 'Cancel queued messages'
+complete -c yoy -n '__fish_seen_subcommand_from outbox' -a 'flush' -d 'Send due messages now'
+complete -c yoy -n '__fish_seen_subcommand_from outbox' -a 'run' -d 'Send messages as they become due'
+
 # config subcommands
 complete -c yoy -n '__fish_seen_subcommand_from config' -a 'get' -d 'Get a config value'
 complete -c yoy -n '__fish_seen_subcommand_from config' -a 'set' -d 'Set a config value'
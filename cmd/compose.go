@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+// bodyFlags select where a message body comes from. With neither flag the
+// body is written in $EDITOR, if stdin is a terminal.
+type bodyFlags struct {
+	Body     string `help:"Message body text." xor:"body"`
+	BodyFile string `help:"Read the body from FILE, or from stdin if FILE is '-'." name:"body-file" placeholder:"FILE" xor:"body"`
+}
+
+// given returns the body set by --body or --body-file, and whether either
+// was set.
+func (f *bodyFlags) given() (string, bool, error) {
+	switch {
+	case f.Body != "":
+		return f.Body, true, nil
+	case f.BodyFile == "-":
+		body, err := readStdin()
+		return body, true, err
+	case f.BodyFile != "":
+		data, err := os.ReadFile(f.BodyFile)
+		if err != nil {
+			return "", true, yoyerrors.Wrap("reading body file", err, yoyerrors.ExitInvalidInput)
+		}
+		return string(data), true, nil
+	}
+	return "", false, nil
+}
+
+// fill sets the body of msg from --body or --body-file, or else opens msg in
+// the editor and reports that it did. With html set the body may stay empty,
+// to be rendered from the HTML file.
+func (f *bodyFlags) fill(msg *composition, html bool) (edited bool, err error) {
+	body, ok, err := f.given()
+	switch {
+	case err != nil:
+		return false, err
+	case ok:
+		msg.Body = body
+	case html:
+		// The text part is rendered from the HTML file.
+	case stdinPiped():
+		return false, noBody()
+	default:
+		return true, msg.edit()
+	}
+	return false, nil
+}
+
+// noBody is the error for a message without a body when no editor can be
+// opened.
+func noBody() error {
+	return yoyerrors.New("no message body", yoyerrors.ExitInvalidInput).
+		WithHint("Pass --body or --body-file ('-' reads stdin), or run from a terminal to write it in $EDITOR.")
+}
+
+// stdinPiped reports whether stdin is not a terminal.
+func stdinPiped() bool {
+	fd := os.Stdin.Fd()
+	return !isatty.IsTerminal(fd) && !isatty.IsCygwinTerminal(fd)
+}
+
+// readStdin reads a message body from stdin. An empty body is an error, so
+// that a script with nothing to say doesn't send an empty message.
+func readStdin() (string, error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", yoyerrors.Wrap("reading body from stdin", err, yoyerrors.ExitInvalidInput)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", yoyerrors.New("message body read from stdin is empty", yoyerrors.ExitInvalidInput)
+	}
+	return string(data), nil
+}
+
+// composition is a message as edited in $EDITOR: header lines, a blank line
+// and the body, the way mutt presents it.
+type composition struct {
+	To      []string
+	Cc      []string
+	Subject string
+	Body    string
+}
+
+// String renders the editor buffer.
+func (c *composition) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "To: %s\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&b, "Cc: %s\n", strings.Join(c.Cc, ", "))
+	fmt.Fprintf(&b, "Subject: %s\n", c.Subject)
+	b.WriteString("\n")
+	b.WriteString(c.Body)
+	return b.String()
+}
+
+// parseComposition reads an editor buffer back. Header lines run up to the
+// first blank line; To and Cc take comma-separated addresses, with or
+// without display names.
+func parseComposition(text string) (*composition, error) {
+	c := &composition{}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	n := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		n += len(line) + 1
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, yoyerrors.New(fmt.Sprintf("invalid header line %q", line), yoyerrors.ExitInvalidInput).
+				WithHint("Keep the To, Cc and Subject lines and a blank line before the body.")
+		}
+		value = strings.TrimSpace(value)
+
+		var err error
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "to":
+			c.To, err = parseAddresses("To", value)
+		case "cc":
+			c.Cc, err = parseAddresses("Cc", value)
+		case "subject":
+			c.Subject = value
+		default:
+			return nil, yoyerrors.New(fmt.Sprintf("unknown header %q", key), yoyerrors.ExitInvalidInput).
+				WithHint("Only To, Cc and Subject can be edited.")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if n < len(text) {
+		c.Body = text[n:]
+	}
+	return c, nil
+}
+
+// parseAddresses parses a comma-separated header value into bare addresses.
+func parseAddresses(header, value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+	list, err := mail.ParseAddressList(value)
+	if err != nil {
+		return nil, yoyerrors.Wrap("invalid "+header+" addresses", err, yoyerrors.ExitInvalidInput)
+	}
+	addrs := make([]string, len(list))
+	for i, a := range list {
+		addrs[i] = a.Address
+	}
+	return addrs, nil
+}
+
+// edit opens c in the user's editor and replaces it with the edited result.
+// A message left unchanged (say, a reply holding only the quote) or without
+// a body is aborted.
+func (c *composition) edit() error {
+	f, err := os.CreateTemp("", "yoy-*.eml")
+	if err != nil {
+		return yoyerrors.Wrap("creating message file", err, yoyerrors.ExitGeneral)
+	}
+	defer os.Remove(f.Name())
+
+	initial := c.String()
+	_, err = f.WriteString(initial)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return yoyerrors.Wrap("writing message file", err, yoyerrors.ExitGeneral)
+	}
+
+	if err := runEditor(f.Name()); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return yoyerrors.Wrap("reading message file", err, yoyerrors.ExitGeneral)
+	}
+	if string(data) == initial {
+		return yoyerrors.New("message unchanged, aborting", yoyerrors.ExitInvalidInput)
+	}
+	edited, err := parseComposition(string(data))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(edited.Body) == "" {
+		return yoyerrors.New("message body is empty, aborting", yoyerrors.ExitInvalidInput)
+	}
+	edited.Body = strings.TrimLeft(edited.Body, "\n")
+	*c = *edited
+	return nil
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, falling back to vi (notepad
+// on Windows). The variable may include arguments, as in "code --wait".
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return yoyerrors.Wrap("running editor "+args[0], err, yoyerrors.ExitGeneral).
+			WithHint("Set $EDITOR to your editor, or pass --body or --body-file.")
+	}
+	return nil
+}
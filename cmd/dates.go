@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+// parseTimeBound parses a --since or --until value: a date (YYYY-MM-DD), a
+// date and time (RFC 3339), or an age such as 24h, 7d or 2w before now.
+// A bare date used as an upper bound includes the whole day.
+func parseTimeBound(flag, value string, now time.Time, upper bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if upper {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if age, err := parseAge(value); err == nil {
+		return now.Add(-age), nil
+	}
+
+	return time.Time{}, yoyerrors.New(fmt.Sprintf("invalid %s %q", flag, value), yoyerrors.ExitInvalidInput).
+		WithHint("Use a date (2024-01-31), a timestamp (2024-01-31T09:00:00Z) or an age (24h, 7d, 2w).")
+}
+
+// parseAge parses a duration, also accepting days (d) and weeks (w).
+func parseAge(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+// parseSendAt parses a --at value: a local date and time (2026-10-20 09:00),
+// a timestamp (RFC 3339), a time of day (09:00, tomorrow if it has passed)
+// or a delay such as 30m, 2h or 1d from now. The time must be in the future.
+func parseSendAt(value string, now time.Time) (time.Time, error) {
+	var at time.Time
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		at = t
+	} else if d, err := parseAge(value); err == nil {
+		at = now.Add(d)
+	} else if t, err := time.ParseInLocation("15:04", value, time.Local); err == nil {
+		y, m, d := now.Date()
+		at = time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, time.Local)
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+	} else {
+		for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05"} {
+			if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				at = t
+				break
+			}
+		}
+	}
+
+	if at.IsZero() {
+		return time.Time{}, yoyerrors.New(fmt.Sprintf("invalid --at %q", value), yoyerrors.ExitInvalidInput).
+			WithHint("Use a date and time (2026-10-20 09:00), a time of day (09:00) or a delay (30m, 2h, 1d).")
+	}
+	if !at.After(now) {
+		return time.Time{}, yoyerrors.New(fmt.Sprintf("--at %q is in the past", value), yoyerrors.ExitInvalidInput)
+	}
+	return at, nil
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/emersion/go-imap/v2"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+	"github.com/Softorize/yoy/internal/yahoo"
+)
+
+// DraftCmd groups draft subcommands. Drafts are kept in the server's Drafts
+// folder, so they can be started in one client and finished in another.
+type DraftCmd struct {
+	Save DraftSaveCmd `cmd:"" help:"Save a new draft."`
+	List DraftListCmd `cmd:"" help:"List drafts."`
+	Edit DraftEditCmd `cmd:"" help:"Edit a draft in $EDITOR."`
+	Send DraftSendCmd `cmd:"" help:"Send a draft and remove it."`
+}
+
+// DraftSaveCmd saves a new draft.
+type DraftSaveCmd struct {
+	To      []string `help:"Recipient email addresses." sep:","`
+	Subject string   `help:"Email subject."`
+	Cc      []string `help:"CC recipients." sep:","`
+	Bcc     []string `help:"BCC recipients." sep:","`
+	Attach  []string `help:"Attach a file (repeatable)." type:"existingfile" sep:"none"`
+
+	bodyFlags `embed:""`
+	htmlFlags `embed:""`
+}
+
+// Run saves the draft.
+func (c *DraftSaveCmd) Run(ctx *Context) error {
+	email, err := ctx.Email()
+	if err != nil {
+		return err
+	}
+
+	msg := &composition{To: c.To, Cc: c.Cc, Subject: c.Subject}
+	if _, err := c.fill(msg, c.HTML != ""); err != nil {
+		return err
+	}
+
+	opts := &yahoo.SendOptions{
+		From:        email,
+		To:          msg.To,
+		Cc:          msg.Cc,
+		Bcc:         c.Bcc,
+		Subject:     msg.Subject,
+		Body:        msg.Body,
+		Attachments: c.Attach,
+	}
+	if err := c.htmlFlags.apply(opts); err != nil {
+		return err
+	}
+
+	uid, err := saveDraft(ctx, opts)
+	if err != nil {
+		return err
+	}
+	printDraftSaved(uid)
+	return nil
+}
+
+// DraftListCmd lists drafts.
+type DraftListCmd struct {
+	Limit   uint32 `help:"Number of drafts to show." short:"n" default:"25"`
+	Preview bool   `help:"Show the start of each draft's text."`
+}
+
+// Run lists drafts, newest first.
+func (c *DraftListCmd) Run(ctx *Context) error {
+	client, err := ctx.IMAPClient()
+	if err != nil {
+		return err
+	}
+	folder, err := client.SpecialFolder(imap.MailboxAttrDrafts)
+	if err != nil {
+		return err
+	}
+
+	page, err := client.ListMessages(folder, &yahoo.ListOptions{Limit: c.Limit, Preview: c.Preview})
+	if err != nil {
+		return err
+	}
+
+	if len(page.Messages) == 0 {
+		fmt.Println("No drafts found.")
+		return nil
+	}
+
+	return ctx.Formatter().FormatMessagePage(os.Stdout, page)
+}
+
+// DraftEditCmd edits a draft.
+type DraftEditCmd struct {
+	UID uint32 `arg:"" help:"Draft UID to edit."`
+}
+
+// Run opens the draft in the editor and replaces it with the result.
+func (c *DraftEditCmd) Run(ctx *Context) error {
+	email, err := ctx.Email()
+	if err != nil {
+		return err
+	}
+
+	folder, _, opts, err := readDraft(ctx, c.UID)
+	if err != nil {
+		return err
+	}
+
+	msg := &composition{To: opts.To, Cc: opts.Cc, Subject: opts.Subject, Body: opts.Body}
+	if err := msg.edit(); err != nil {
+		return err
+	}
+
+	// The HTML version no longer matches an edited text.
+	if msg.Body != opts.Body {
+		opts.HTMLBody = ""
+	}
+	opts.From = email
+	opts.To, opts.Cc, opts.Subject, opts.Body = msg.To, msg.Cc, msg.Subject, msg.Body
+
+	uid, err := saveDraft(ctx, opts)
+	if err != nil {
+		return err
+	}
+	removeDraft(ctx, folder, c.UID)
+
+	printDraftSaved(uid)
+	return nil
+}
+
+// DraftSendCmd sends a draft.
+type DraftSendCmd struct {
+	UID uint32 `arg:"" help:"Draft UID to send."`
+}
+
+// Run sends the draft as saved, then removes it from Drafts.
+func (c *DraftSendCmd) Run(ctx *Context) error {
+	email, err := ctx.Email()
+	if err != nil {
+		return err
+	}
+
+	folder, raw, opts, err := readDraft(ctx, c.UID)
+	if err != nil {
+		return err
+	}
+	if len(opts.To) == 0 {
+		return yoyerrors.New("draft has no recipients", yoyerrors.ExitInvalidInput).
+			WithHint(fmt.Sprintf("Add them with 'yoy draft edit %d'.", c.UID))
+	}
+
+	// Send the draft as it was saved, so that parts yoy does not compose
+	// itself reach the recipients unchanged.
+	sent, err := yahoo.SendRaw(email, raw)
+	if err != nil {
+		return err
+	}
+	keepSent(ctx, sent)
+	removeDraft(ctx, folder, c.UID)
+
+	fmt.Println("Draft sent.")
+	return nil
+}
+
+// readDraft fetches a draft and returns its folder, raw message and
+// contents.
+func readDraft(ctx *Context, uid uint32) (string, []byte, *yahoo.SendOptions, error) {
+	client, err := ctx.IMAPClient()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	folder, err := client.SpecialFolder(imap.MailboxAttrDrafts)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	raw, err := client.FetchRawMessage(folder, uid)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	opts, err := yahoo.ParseDraft(raw)
+	if err != nil {
+		return "", nil, nil, yoyerrors.Wrap("reading draft", err, yoyerrors.ExitGeneral)
+	}
+	return folder, raw, opts, nil
+}
+
+// saveDraft appends a new draft to the Drafts folder and returns its UID, or
+// 0 if the server does not report it.
+func saveDraft(ctx *Context, opts *yahoo.SendOptions) (uint32, error) {
+	client, err := ctx.IMAPClient()
+	if err != nil {
+		return 0, err
+	}
+	folder, err := client.SpecialFolder(imap.MailboxAttrDrafts)
+	if err != nil {
+		return 0, err
+	}
+
+	raw, err := yahoo.ComposeDraft(opts)
+	if err != nil {
+		return 0, yoyerrors.Wrap("composing draft", err, yoyerrors.ExitGeneral)
+	}
+	return client.AppendMessage(folder, raw, []imap.Flag{imap.FlagDraft, imap.FlagSeen})
+}
+
+// removeDraft deletes a draft that has been replaced or sent. It is removed
+// for good rather than moved to Trash; a failure only warns.
+func removeDraft(ctx *Context, folder string, uid uint32) {
+	client, err := ctx.IMAPClient()
+	if err == nil {
+		err = client.DeleteMessagesPermanently(folder, imap.UIDSetNum(imap.UID(uid)))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: draft %d was not removed: %v\n", uid, err)
+		if hint := yoyerrors.HintFrom(err); hint != "" {
+			fmt.Fprintln(os.Stderr, hint)
+		}
+	}
+}
+
+// printDraftSaved reports a saved draft and its UID, when known.
+func printDraftSaved(uid uint32) {
+	if uid == 0 {
+		fmt.Println("Draft saved.")
+		return
+	}
+	fmt.Printf("Draft saved (UID %d).\n", uid)
+}
@@ -1,35 +1,107 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
+	"iter"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/emersion/go-imap/v2"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+	"github.com/Softorize/yoy/internal/output"
 	"github.com/Softorize/yoy/internal/yahoo"
 )
 
 // MailCmd groups all mail subcommands.
 type MailCmd struct {
-	List       MailListCmd       `cmd:"" help:"List messages in a folder."`
-	Search     MailSearchCmd     `cmd:"" help:"Search messages."`
-	Read       MailReadCmd       `cmd:"" help:"Read a message."`
-	Send       SendCmd           `cmd:"" help:"Send a new email."`
-	Reply      MailReplyCmd      `cmd:"" help:"Reply to a message."`
-	Forward    MailForwardCmd    `cmd:"" help:"Forward a message."`
-	Delete     MailDeleteCmd     `cmd:"" help:"Delete a message."`
-	Move       MailMoveCmd       `cmd:"" help:"Move a message to another folder."`
-	Star       MailStarCmd       `cmd:"" help:"Star a message."`
-	Unstar     MailUnstarCmd     `cmd:"" help:"Unstar a message."`
-	MarkRead   MailMarkReadCmd   `cmd:"" help:"Mark a message as read."`
-	MarkUnread MailMarkUnreadCmd `cmd:"" help:"Mark a message as unread."`
+	List        MailListCmd        `cmd:"" help:"List messages in a folder."`
+	Search      MailSearchCmd      `cmd:"" help:"Search messages."`
+	Read        MailReadCmd        `cmd:"" help:"Read a message."`
+	Thread      MailThreadCmd      `cmd:"" help:"Show the conversation a message belongs to."`
+	Raw         MailRawCmd         `cmd:"" help:"Print the raw RFC 5322 source of a message."`
+	Attachments MailAttachmentsCmd `cmd:"" help:"List or save message attachments."`
+	Send        SendCmd            `cmd:"" help:"Send a new email."`
+	Reply       MailReplyCmd       `cmd:"" help:"Reply to a message."`
+	Forward     MailForwardCmd     `cmd:"" help:"Forward a message."`
+	Delete      MailDeleteCmd      `cmd:"" help:"Delete messages (moves them to Trash)."`
+	Move        MailMoveCmd        `cmd:"" help:"Move messages to another folder."`
+	Star        MailStarCmd        `cmd:"" help:"Star messages."`
+	Unstar      MailUnstarCmd      `cmd:"" help:"Unstar messages."`
+	MarkRead    MailMarkReadCmd    `cmd:"" help:"Mark messages as read."`
+	MarkUnread  MailMarkUnreadCmd  `cmd:"" help:"Mark messages as unread."`
+}
+
+// sortFlags selects the order of a message listing.
+type sortFlags struct {
+	Sort    string `help:"Sort by date, arrival, from, subject or size." placeholder:"KEY"`
+	Reverse bool   `help:"Reverse the sort order."`
+}
+
+// order returns the requested sort order. --reverse alone lists oldest first.
+func (f *sortFlags) order() (yahoo.SortOrder, error) {
+	if f.Sort == "" {
+		if f.Reverse {
+			return yahoo.SortOrder{Key: yahoo.SortArrival, Reverse: true}, nil
+		}
+		return yahoo.SortOrder{}, nil
+	}
+
+	key, err := yahoo.ParseSortKey(f.Sort)
+	if err != nil {
+		return yahoo.SortOrder{}, err
+	}
+	return yahoo.SortOrder{Key: key, Reverse: f.Reverse}, nil
 }
 
 // MailListCmd lists messages in a folder.
 type MailListCmd struct {
-	Limit uint32 `help:"Number of messages to show." short:"n" default:"25"`
+	Limit     uint32 `help:"Number of messages to show." short:"n" default:"25"`
+	Page      uint32 `help:"Page number, counted from the newest (or the cursor)." default:"1"`
+	Offset    uint32 `help:"Number of messages to skip before the page." placeholder:"N"`
+	BeforeUID uint32 `help:"Only messages with a UID below this cursor." name:"before-uid" placeholder:"UID" xor:"cursor"`
+	AfterUID  uint32 `help:"Only messages with a UID above this cursor." name:"after-uid" placeholder:"UID" xor:"cursor"`
+
+	Unread        bool   `help:"Only unread messages."`
+	Flagged       bool   `help:"Only starred messages."`
+	Answered      bool   `help:"Only answered messages."`
+	Since         string `help:"Only messages received since a date, time or age (2024-01-31, 24h, 7d)." placeholder:"WHEN"`
+	Until         string `help:"Only messages received until a date, time or age." placeholder:"WHEN"`
+	From          string `help:"Only messages whose From header contains this text." placeholder:"ADDR"`
+	HasAttachment bool   `help:"Only messages with attachments." name:"has-attachment"`
+
+	sortFlags `embed:""`
+
+	Preview bool `help:"Show the start of each message's text."`
+	Threads bool `help:"Show each conversation as one row, with its message count."`
 }
 
 // Run lists messages.
 func (c *MailListCmd) Run(ctx *Context) error {
+	if c.Page == 0 {
+		return yoyerrors.New("--page starts at 1", yoyerrors.ExitInvalidInput)
+	}
+
+	now := time.Now()
+	since, err := parseTimeBound("--since", c.Since, now, false)
+	if err != nil {
+		return err
+	}
+	until, err := parseTimeBound("--until", c.Until, now, true)
+	if err != nil {
+		return err
+	}
+	order, err := c.order()
+	if err != nil {
+		return err
+	}
+	if c.Threads && order.Key != "" {
+		return yoyerrors.New("--threads cannot be combined with --sort or --reverse", yoyerrors.ExitInvalidInput).
+			WithHint("Conversations are listed most recently active first.")
+	}
+
 	client, err := ctx.IMAPClient()
 	if err != nil {
 		return err
@@ -40,22 +112,91 @@ func (c *MailListCmd) Run(ctx *Context) error {
 		limit = uint32(ctx.Config.MailLimit)
 	}
 
-	messages, err := client.ListMessages(ctx.Folder, limit)
+	opts := &yahoo.ListOptions{
+		Limit:     limit,
+		Offset:    (c.Page-1)*limit + c.Offset,
+		BeforeUID: c.BeforeUID,
+		AfterUID:  c.AfterUID,
+
+		Unread:        c.Unread,
+		Flagged:       c.Flagged,
+		Answered:      c.Answered,
+		HasAttachment: c.HasAttachment,
+		From:          c.From,
+		Since:         since,
+		Until:         until,
+
+		Sort:    order,
+		Preview: c.Preview,
+	}
+
+	f := ctx.Formatter()
+	var page *yahoo.MessagePage
+	if c.Threads {
+		page, err = client.ListThreads(ctx.Folder, opts)
+	} else {
+		if s, ok := f.(output.MessageStreamer); ok {
+			messages, next := client.StreamMessages(ctx.Folder, opts)
+			if err := streamMessages(s, messages, output.StreamOptions{Previews: c.Preview, NextCursor: next}); err != nil {
+				return err
+			}
+			reportCursor(f, next())
+			return nil
+		}
+		page, err = client.ListMessages(ctx.Folder, opts)
+	}
 	if err != nil {
 		return err
 	}
 
-	if len(messages) == 0 {
+	if len(page.Messages) == 0 && !output.WritesEmptyPage(f) {
 		fmt.Println("No messages found.")
 		return nil
 	}
 
-	return ctx.Formatter().FormatMessages(os.Stdout, messages)
+	if err := f.FormatMessagePage(os.Stdout, page); err != nil {
+		return err
+	}
+	reportCursor(f, page.NextCursor)
+	return nil
+}
+
+// streamMessages writes messages with s as they are fetched, and says so
+// when there are none, like the formats that wait for the whole listing.
+func streamMessages(s output.MessageStreamer, messages iter.Seq2[yahoo.Message, error], opts output.StreamOptions) error {
+	found := false
+	counted := func(yield func(yahoo.Message, error) bool) {
+		for m, err := range messages {
+			found = found || err == nil
+			if !yield(m, err) {
+				return
+			}
+		}
+	}
+	if err := s.StreamMessages(os.Stdout, counted, opts); err != nil {
+		return err
+	}
+	if !found {
+		fmt.Println("No messages found.")
+	}
+	return nil
+}
+
+// reportCursor prints the next cursor of a listing to stderr when the
+// output format has no place for it, keeping stdout to the messages.
+func reportCursor(f output.Formatter, cursor uint32) {
+	if cursor != 0 && !output.WritesCursor(f) {
+		fmt.Fprintf(os.Stderr, "Next cursor: %d\n", cursor)
+	}
 }
 
 // MailSearchCmd searches for messages.
 type MailSearchCmd struct {
-	Query string `arg:"" help:"Search query."`
+	Query      []string `arg:"" help:"Search query, e.g. \"from:alice is:unread after:2024-01-01\"."`
+	AllFolders bool     `help:"Search every folder." name:"all-folders" xor:"folders"`
+	Folders    []string `help:"Search these folders instead of --folder." sep:"," placeholder:"A,B" xor:"folders"`
+
+	sortFlags `embed:""`
 }
 
 // Run searches messages.
@@ -65,7 +206,29 @@ func (c *MailSearchCmd) Run(ctx *Context) error {
 		return err
 	}
 
-	messages, err := client.SearchMessages(ctx.Folder, c.Query)
+	query := strings.Join(c.Query, " ")
+
+	order, err := c.order()
+	if err != nil {
+		return err
+	}
+
+	var messages []yahoo.Message
+	switch {
+	case c.AllFolders:
+		var folders []string
+		if folders, err = client.MailboxNames(); err != nil {
+			return err
+		}
+		messages, err = client.SearchFolders(folders, query, order)
+	case len(c.Folders) > 0:
+		messages, err = client.SearchFolders(c.Folders, query, order)
+	default:
+		if s, ok := ctx.Formatter().(output.MessageStreamer); ok {
+			return streamMessages(s, client.StreamSearch(ctx.Folder, query, order), output.StreamOptions{})
+		}
+		messages, err = client.SearchMessages(ctx.Folder, query, order)
+	}
 	if err != nil {
 		return err
 	}
@@ -78,9 +241,32 @@ func (c *MailSearchCmd) Run(ctx *Context) error {
 	return ctx.Formatter().FormatMessages(os.Stdout, messages)
 }
 
+// MailThreadCmd shows a conversation as a tree.
+type MailThreadCmd struct {
+	UID uint32 `arg:"" help:"UID of any message in the conversation."`
+}
+
+// Run shows the conversation.
+func (c *MailThreadCmd) Run(ctx *Context) error {
+	client, err := ctx.IMAPClient()
+	if err != nil {
+		return err
+	}
+
+	messages, err := client.ThreadMessages(ctx.Folder, c.UID)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Formatter().FormatMessages(os.Stdout, messages)
+}
+
 // MailReadCmd reads a message by UID.
 type MailReadCmd struct {
-	UID uint32 `arg:"" help:"Message UID."`
+	UID     uint32 `arg:"" help:"Message UID."`
+	HTML    bool   `help:"Show the HTML part rendered as text." xor:"body"`
+	Text    bool   `help:"Show only the text/plain part." xor:"body"`
+	RawHTML bool   `help:"Show the HTML part source." name:"raw-html" xor:"body"`
 }
 
 // Run reads a message.
@@ -95,135 +281,251 @@ func (c *MailReadCmd) Run(ctx *Context) error {
 		return err
 	}
 
+	switch {
+	case c.HTML:
+		if message.HTMLBody == "" {
+			return yoyerrors.New("message has no HTML part", yoyerrors.ExitNotFound).
+				WithHint("Read it without --html to see the text part.")
+		}
+		message.Body = yahoo.HTMLToText(message.HTMLBody)
+	case c.RawHTML:
+		if message.HTMLBody == "" {
+			return yoyerrors.New("message has no HTML part", yoyerrors.ExitNotFound).
+				WithHint("Read it without --raw-html to see the text part.")
+		}
+		message.Body = message.HTMLBody
+	case c.Text:
+		if message.BodyFromHTML {
+			return yoyerrors.New("message has no text/plain part", yoyerrors.ExitNotFound).
+				WithHint("Use --html to render the HTML part as text.")
+		}
+	}
+
 	return ctx.Formatter().FormatMessage(os.Stdout, message)
 }
 
-// MailDeleteCmd deletes a message.
-type MailDeleteCmd struct {
+// MailRawCmd prints the original source of a message.
+type MailRawCmd struct {
 	UID uint32 `arg:"" help:"Message UID."`
+	Eml string `help:"Write the source to this .eml file instead of stdout, never overwriting an existing file." type:"path" placeholder:"FILE"`
 }
 
-// Run deletes a message.
+// Run writes the message source to stdout or the --eml file.
+func (c *MailRawCmd) Run(ctx *Context) error {
+	client, err := ctx.IMAPClient()
+	if err != nil {
+		return err
+	}
+
+	if c.Eml == "" {
+		return client.WriteRawMessage(ctx.Folder, c.UID, os.Stdout)
+	}
+
+	// An existing file is never overwritten; like attachments, the source
+	// is saved as "name (N).eml" instead.
+	f, path, err := createUnique(c.Eml)
+	if err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+
+	if err := client.WriteRawMessage(ctx.Folder, c.UID, f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+
+	fmt.Printf("Saved %s\n", path)
+	return nil
+}
+
+// MailDeleteCmd deletes messages.
+type MailDeleteCmd struct {
+	messageSelection `embed:""`
+	Permanent        bool `help:"Delete permanently instead of moving to Trash."`
+}
+
+// Run deletes messages.
 func (c *MailDeleteCmd) Run(ctx *Context) error {
 	client, err := ctx.IMAPClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.DeleteMessage(ctx.Folder, c.UID); err != nil {
+	uids, n, err := c.resolve(client, ctx.Folder)
+	if err != nil || n == 0 {
+		return err
+	}
+
+	if c.Permanent {
+		if err := client.DeleteMessagesPermanently(ctx.Folder, uids); err != nil {
+			return err
+		}
+		fmt.Printf("%s permanently deleted.\n", describeMessages(uids, n))
+		return nil
+	}
+
+	trash, err := client.DeleteMessages(ctx.Folder, uids)
+	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Message %d deleted.\n", c.UID)
+	fmt.Printf("%s moved to %s.\n", describeMessages(uids, n), trash)
 	return nil
 }
 
-// MailMoveCmd moves a message to another folder.
+// MailMoveCmd moves messages to another folder.
 type MailMoveCmd struct {
-	UID        uint32 `arg:"" help:"Message UID."`
-	DestFolder string `arg:"" help:"Destination folder."`
+	messageSelection `embed:""`
+	DestFolder       string `arg:"" optional:"" help:"Destination folder."`
 }
 
-// Run moves a message.
+// Run moves messages.
 func (c *MailMoveCmd) Run(ctx *Context) error {
+	// With --query the only positional argument is the destination. One
+	// that looks like UIDs more likely means the folder was left out.
+	if c.Query != "" && c.DestFolder == "" {
+		if _, err := yahoo.ParseUIDSet(c.UIDs); err == nil {
+			return yoyerrors.New(fmt.Sprintf("missing destination folder (%q looks like UIDs)", c.UIDs), yoyerrors.ExitInvalidInput).
+				WithHint(fmt.Sprintf("Usage: yoy mail move --query QUERY FOLDER. To move into a folder named %q, pass 1:* before it.", c.UIDs))
+		}
+		c.UIDs, c.DestFolder = "", c.UIDs
+	}
+	if c.DestFolder == "" {
+		return yoyerrors.New("missing destination folder", yoyerrors.ExitInvalidInput).
+			WithHint("Usage: yoy mail move UIDS FOLDER or yoy mail move --query QUERY FOLDER")
+	}
+
 	client, err := ctx.IMAPClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.MoveMessage(ctx.Folder, c.UID, c.DestFolder); err != nil {
+	uids, n, err := c.resolve(client, ctx.Folder)
+	if err != nil || n == 0 {
 		return err
 	}
 
-	fmt.Printf("Message %d moved to %s.\n", c.UID, c.DestFolder)
+	if err := client.MoveMessages(ctx.Folder, uids, c.DestFolder); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s moved to %s.\n", describeMessages(uids, n), c.DestFolder)
 	return nil
 }
 
-// MailStarCmd stars a message.
+// MailStarCmd stars messages.
 type MailStarCmd struct {
-	UID uint32 `arg:"" help:"Message UID."`
+	messageSelection `embed:""`
 }
 
-// Run stars a message.
+// Run stars messages.
 func (c *MailStarCmd) Run(ctx *Context) error {
 	client, err := ctx.IMAPClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.StarMessage(ctx.Folder, c.UID); err != nil {
+	uids, n, err := c.resolve(client, ctx.Folder)
+	if err != nil || n == 0 {
 		return err
 	}
 
-	fmt.Printf("Message %d starred.\n", c.UID)
+	if err := client.StarMessages(ctx.Folder, uids); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s starred.\n", describeMessages(uids, n))
 	return nil
 }
 
-// MailUnstarCmd unstars a message.
+// MailUnstarCmd unstars messages.
 type MailUnstarCmd struct {
-	UID uint32 `arg:"" help:"Message UID."`
+	messageSelection `embed:""`
 }
 
-// Run unstars a message.
+// Run unstars messages.
 func (c *MailUnstarCmd) Run(ctx *Context) error {
 	client, err := ctx.IMAPClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.UnstarMessage(ctx.Folder, c.UID); err != nil {
+	uids, n, err := c.resolve(client, ctx.Folder)
+	if err != nil || n == 0 {
 		return err
 	}
 
-	fmt.Printf("Message %d unstarred.\n", c.UID)
+	if err := client.UnstarMessages(ctx.Folder, uids); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s unstarred.\n", describeMessages(uids, n))
 	return nil
 }
 
-// MailMarkReadCmd marks a message as read.
+// MailMarkReadCmd marks messages as read.
 type MailMarkReadCmd struct {
-	UID uint32 `arg:"" help:"Message UID."`
+	messageSelection `embed:""`
 }
 
-// Run marks a message as read.
+// Run marks messages as read.
 func (c *MailMarkReadCmd) Run(ctx *Context) error {
 	client, err := ctx.IMAPClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.MarkRead(ctx.Folder, c.UID); err != nil {
+	uids, n, err := c.resolve(client, ctx.Folder)
+	if err != nil || n == 0 {
+		return err
+	}
+
+	if err := client.MarkRead(ctx.Folder, uids); err != nil {
 		return err
 	}
 
-	fmt.Printf("Message %d marked as read.\n", c.UID)
+	fmt.Printf("%s marked as read.\n", describeMessages(uids, n))
 	return nil
 }
 
-// MailMarkUnreadCmd marks a message as unread.
+// MailMarkUnreadCmd marks messages as unread.
 type MailMarkUnreadCmd struct {
-	UID uint32 `arg:"" help:"Message UID."`
+	messageSelection `embed:""`
 }
 
-// Run marks a message as unread.
+// Run marks messages as unread.
 func (c *MailMarkUnreadCmd) Run(ctx *Context) error {
 	client, err := ctx.IMAPClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.MarkUnread(ctx.Folder, c.UID); err != nil {
+	uids, n, err := c.resolve(client, ctx.Folder)
+	if err != nil || n == 0 {
 		return err
 	}
 
-	fmt.Printf("Message %d marked as unread.\n", c.UID)
+	if err := client.MarkUnread(ctx.Folder, uids); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s marked as unread.\n", describeMessages(uids, n))
 	return nil
 }
 
 // MailReplyCmd replies to a message.
 type MailReplyCmd struct {
-	UID  uint32 `arg:"" help:"Message UID to reply to."`
-	Body string `help:"Reply body text." required:""`
-	All  bool   `help:"Reply to all recipients." default:"false"`
+	UID     uint32   `arg:"" help:"Message UID to reply to."`
+	All     bool     `help:"Reply to all recipients." default:"false"`
+	NoQuote bool     `help:"Don't quote the original message." name:"no-quote"`
+	Attach  []string `help:"Attach a file (repeatable)." type:"existingfile" sep:"none"`
+
+	bodyFlags `embed:""`
+	htmlFlags `embed:""`
 }
 
 // Run replies to a message.
@@ -246,55 +548,81 @@ func (c *MailReplyCmd) Run(ctx *Context) error {
 
 	// Build reply.
 	subject := original.Subject
-	if len(subject) < 4 || subject[:4] != "Re: " {
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
 		subject = "Re: " + subject
 	}
 
-	to := []string{original.From.Address}
-	if c.All {
-		for _, addr := range original.To {
-			if addr.Address != email {
-				to = append(to, addr.Address)
-			}
+	to, cc := yahoo.ReplyRecipients(original, email, c.All)
+	msg := &composition{To: to, Cc: cc, Subject: subject}
+
+	quote := !c.NoQuote
+	body, ok, err := c.given()
+	switch {
+	case err != nil:
+		return err
+	case ok:
+		msg.Body = body
+	case c.HTML != "":
+		// The text part is rendered from the HTML file.
+		quote = false
+	case stdinPiped():
+		return noBody()
+	default:
+		// The quote goes into the editor, below the reply.
+		if quote {
+			msg.Body = "\n\n" + yahoo.QuoteMessage(original)
+			quote = false
 		}
-		for _, addr := range original.Cc {
-			if addr.Address != email {
-				to = append(to, addr.Address)
-			}
+		if err := msg.edit(); err != nil {
+			return err
 		}
 	}
-
-	headers := map[string]string{}
-	if original.MessageID != "" {
-		headers["In-Reply-To"] = original.MessageID
-		headers["References"] = original.MessageID
+	if quote {
+		msg.Body = strings.TrimRight(msg.Body, "\n") + "\n\n" + yahoo.QuoteMessage(original)
+	}
+	if len(msg.To) == 0 {
+		return yoyerrors.New("no recipients", yoyerrors.ExitInvalidInput)
 	}
 
 	opts := &yahoo.SendOptions{
-		From:    email,
-		To:      to,
-		Subject: subject,
-		Body:    c.Body,
-		Headers: headers,
+		From:        email,
+		To:          msg.To,
+		Cc:          msg.Cc,
+		Subject:     msg.Subject,
+		Body:        msg.Body,
+		Attachments: c.Attach,
+	}
+	if err := c.htmlFlags.apply(opts); err != nil {
+		return err
+	}
+	if original.MessageID != "" {
+		opts.ReplyTo = yahoo.FormatMessageID(original.MessageID)
+		opts.Headers = map[string]string{
+			"References": strings.Join(yahoo.ReplyReferences(original), " "),
+		}
 	}
 
-	if err := yahoo.SendMail(email, opts); err != nil {
+	if err := sendMail(ctx, email, opts); err != nil {
 		return err
 	}
 
 	fmt.Println("Reply sent.")
 
 	// Mark original as read.
-	_ = client.MarkRead(ctx.Folder, c.UID)
+	_ = client.MarkRead(ctx.Folder, imap.UIDSetNum(imap.UID(c.UID)))
 
 	return nil
 }
 
 // MailForwardCmd forwards a message.
 type MailForwardCmd struct {
-	UID  uint32   `arg:"" help:"Message UID to forward."`
-	To   []string `help:"Recipient email addresses." required:"" sep:","`
-	Body string   `help:"Additional message body." default:""`
+	UID          uint32   `arg:"" help:"Message UID to forward."`
+	To           []string `help:"Recipient email addresses." required:"" sep:","`
+	Body         string   `help:"Additional message body." default:""`
+	Attach       []string `help:"Attach a file (repeatable)." type:"existingfile" sep:"none"`
+	AsAttachment bool     `help:"Forward the original as a message/rfc822 attachment." name:"as-attachment"`
+
+	htmlFlags `embed:""`
 }
 
 // Run forwards a message.
@@ -304,8 +632,13 @@ func (c *MailForwardCmd) Run(ctx *Context) error {
 		return err
 	}
 
-	// Fetch the original message.
-	original, err := client.ReadMessage(ctx.Folder, c.UID)
+	// Fetch the original message source; its MIME parts are forwarded as-is.
+	raw, err := client.FetchRawMessage(ctx.Folder, c.UID)
+	if err != nil {
+		return err
+	}
+
+	original, err := yahoo.ParseMessageHeader(bytes.NewReader(raw))
 	if err != nil {
 		return err
 	}
@@ -321,23 +654,32 @@ func (c *MailForwardCmd) Run(ctx *Context) error {
 	}
 
 	body := c.Body
-	if body != "" {
-		body += "\n\n"
+	if !c.AsAttachment {
+		if body != "" {
+			body += "\n\n"
+		}
+		body += "---------- Forwarded message ----------\n"
+		body += fmt.Sprintf("From: %s <%s>\n", original.From.Name, original.From.Address)
+		body += fmt.Sprintf("Date: %s\n", original.Date.Format("2006-01-02 15:04"))
+		body += fmt.Sprintf("Subject: %s\n", original.Subject)
 	}
-	body += "---------- Forwarded message ----------\n"
-	body += fmt.Sprintf("From: %s <%s>\n", original.From.Name, original.From.Address)
-	body += fmt.Sprintf("Date: %s\n", original.Date.Format("2006-01-02 15:04"))
-	body += fmt.Sprintf("Subject: %s\n\n", original.Subject)
-	body += original.Body
 
 	opts := &yahoo.SendOptions{
-		From:    email,
-		To:      c.To,
-		Subject: subject,
-		Body:    body,
+		From:        email,
+		To:          c.To,
+		Subject:     subject,
+		Body:        body,
+		Attachments: c.Attach,
+		Forward: &yahoo.ForwardedMessage{
+			Raw:          raw,
+			AsAttachment: c.AsAttachment,
+		},
+	}
+	if err := c.htmlFlags.apply(opts); err != nil {
+		return err
 	}
 
-	if err := yahoo.SendMail(email, opts); err != nil {
+	if err := sendMail(ctx, email, opts); err != nil {
 		return err
 	}
 
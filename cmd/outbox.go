@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+	"github.com/Softorize/yoy/internal/outbox"
+	"github.com/Softorize/yoy/internal/output"
+	"github.com/Softorize/yoy/internal/yahoo"
+)
+
+// OutboxCmd groups outbox subcommands. The outbox holds messages queued
+// with 'send --at' until they are due.
+type OutboxCmd struct {
+	List   OutboxListCmd   `cmd:"" help:"List queued messages."`
+	Cancel OutboxCancelCmd `cmd:"" help:"Remove queued messages without sending them."`
+	Flush  OutboxFlushCmd  `cmd:"" help:"Send due and failed messages now."`
+	Run    OutboxRunCmd    `cmd:"" help:"Send queued messages as they become due, until interrupted."`
+}
+
+// OutboxListCmd lists queued messages.
+type OutboxListCmd struct{}
+
+// Run lists queued messages in the order they are due.
+func (c *OutboxListCmd) Run(ctx *Context) error {
+	q, err := outbox.Open()
+	if err != nil {
+		return err
+	}
+	entries, err := q.List()
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("The outbox is empty.")
+		return nil
+	}
+
+	return ctx.Formatter().FormatOutbox(os.Stdout, outboxEntries(entries))
+}
+
+// outboxEntries returns queued messages as they are listed, with their
+// status.
+func outboxEntries(entries []outbox.Entry) []output.OutboxEntry {
+	out := make([]output.OutboxEntry, len(entries))
+	for i, e := range entries {
+		out[i] = output.OutboxEntry{
+			ID:          e.ID,
+			SendAt:      e.SendAt,
+			CreatedAt:   e.CreatedAt,
+			To:          e.To,
+			Subject:     e.Subject,
+			Attempts:    e.Attempts,
+			LastAttempt: e.LastAttempt,
+			LastError:   e.LastError,
+			RetryAt:     e.RetryAt,
+			Status:      e.Status(),
+		}
+	}
+	return out
+}
+
+// OutboxCancelCmd removes queued messages.
+type OutboxCancelCmd struct {
+	IDs []string `arg:"" name:"id" help:"IDs of the queued messages."`
+}
+
+// Run removes the messages.
+func (c *OutboxCancelCmd) Run(ctx *Context) error {
+	q, err := outbox.Open()
+	if err != nil {
+		return err
+	}
+
+	for _, id := range c.IDs {
+		if _, err := q.Get(id); err != nil {
+			return err
+		}
+		release, err := q.Lock(id)
+		if errors.Is(err, outbox.ErrLocked) {
+			return yoyerrors.New(fmt.Sprintf("message %s is being sent", id), yoyerrors.ExitGeneral)
+		}
+		if err != nil {
+			return err
+		}
+		err = q.Remove(id)
+		release()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Cancelled %s.\n", id)
+	}
+	return nil
+}
+
+// OutboxFlushCmd sends queued messages now.
+type OutboxFlushCmd struct {
+	All bool `help:"Also send messages scheduled for later."`
+}
+
+// Run sends the messages whose time has come, including ones a dispatcher
+// has given up on, without waiting for retry delays.
+func (c *OutboxFlushCmd) Run(ctx *Context) error {
+	q, err := outbox.Open()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	sent, failed, err := deliver(ctx, q, func(e *outbox.Entry) bool {
+		return c.All || !now.Before(e.SendAt)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d sent, %d failed.\n", sent, failed)
+	if failed > 0 {
+		return yoyerrors.New(fmt.Sprintf("%d messages could not be sent", failed), yoyerrors.ExitSMTPError).
+			WithHint("They stay in the outbox; see 'yoy outbox list' for the errors.")
+	}
+	return nil
+}
+
+// OutboxRunCmd dispatches queued messages.
+type OutboxRunCmd struct {
+	Interval time.Duration `help:"Longest time between checks of the outbox." default:"1m"`
+}
+
+// Run checks the outbox until interrupted, sending messages when they are
+// due. Failed sends are retried with growing delays, up to
+// outbox.MaxAttempts times.
+func (c *OutboxRunCmd) Run(ctx *Context) error {
+	if c.Interval <= 0 {
+		return yoyerrors.New("--interval must be positive", yoyerrors.ExitInvalidInput)
+	}
+	q, err := outbox.Open()
+	if err != nil {
+		return err
+	}
+
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "Watching %s (Ctrl-C to stop).\n", outbox.Dir())
+	for {
+		if _, _, err := deliver(ctx, q, func(e *outbox.Entry) bool { return e.Due(time.Now()) }); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		// The IMAP connection used to save sent mail would time out while
+		// idle; a new one is opened when needed.
+		ctx.Close()
+
+		select {
+		case <-sigCtx.Done():
+			return nil
+		case <-time.After(c.wait(q)):
+		}
+	}
+}
+
+// wait returns how long to sleep until the next message is due, at most
+// the interval.
+func (c *OutboxRunCmd) wait(q *outbox.Queue) time.Duration {
+	wait := c.Interval
+	entries, err := q.List()
+	if err != nil {
+		return wait
+	}
+	for _, e := range entries {
+		if e.Attempts >= outbox.MaxAttempts {
+			continue
+		}
+		if d := time.Until(e.NextAttempt()); d < wait {
+			wait = max(d, time.Second)
+		}
+	}
+	return wait
+}
+
+// queueMail composes a message and adds it to the outbox, to be sent at at.
+func queueMail(opts *yahoo.SendOptions, at time.Time) error {
+	// Composed like a draft, so the Bcc recipients are kept until it is sent.
+	raw, err := yahoo.ComposeDraft(opts)
+	if err != nil {
+		return yoyerrors.Wrap("composing message", err, yoyerrors.ExitGeneral)
+	}
+
+	q, err := outbox.Open()
+	if err != nil {
+		return err
+	}
+	e := &outbox.Entry{
+		SendAt:    at.Truncate(time.Second),
+		CreatedAt: time.Now().Truncate(time.Second),
+		To:        opts.To,
+		Subject:   opts.Subject,
+	}
+	if err := q.Add(raw, e); err != nil {
+		return err
+	}
+
+	fmt.Printf("Message %s queued for %s.\n", e.ID, at.Format("Mon Jan 2 15:04"))
+	fmt.Println("It is sent by 'yoy outbox run', or 'yoy outbox flush' once due.")
+	return nil
+}
+
+// deliver sends the queued messages selected by due, one at a time, and
+// reports each result. A failed send is recorded and left in the outbox. It
+// returns the numbers of messages sent and failed.
+func deliver(ctx *Context, q *outbox.Queue, due func(*outbox.Entry) bool) (sent, failed int, err error) {
+	entries, err := q.List()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var email string
+	for i := range entries {
+		if !due(&entries[i]) {
+			continue
+		}
+		if email == "" {
+			if email, err = ctx.Email(); err != nil {
+				return sent, failed, err
+			}
+		}
+
+		id := entries[i].ID
+		release, err := q.Lock(id)
+		if errors.Is(err, outbox.ErrLocked) {
+			continue
+		}
+		if err != nil {
+			return sent, failed, err
+		}
+		e, sendErr := sendQueued(ctx, q, email, id)
+		release()
+
+		switch {
+		case e == nil:
+			// Sent or cancelled by another process meanwhile.
+		case sendErr != nil:
+			fmt.Fprintf(os.Stderr, "Failed to send %s (attempt %d): %v\n", id, e.Attempts, sendErr)
+			failed++
+		default:
+			fmt.Printf("Sent %s to %s.\n", id, strings.Join(e.To, ", "))
+			sent++
+		}
+	}
+	return sent, failed, nil
+}
+
+// sendQueued sends one locked message and removes it from the outbox, or
+// records the failure and returns the error. The message's record is
+// returned too, or nil if the message is no longer queued.
+func sendQueued(ctx *Context, q *outbox.Queue, email, id string) (*outbox.Entry, error) {
+	e, err := q.Get(id)
+	if err != nil {
+		return nil, nil
+	}
+
+	// The spooled source is sent as composed, so headers such as those set
+	// by a template are kept.
+	raw, err := q.Message(id)
+	if err == nil {
+		raw, err = yahoo.SendRaw(email, raw)
+	}
+	if err != nil {
+		if ferr := q.Fail(e, err, time.Now()); ferr != nil {
+			return e, ferr
+		}
+		return e, err
+	}
+	keepSent(ctx, raw)
+
+	if err := q.Remove(id); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s was sent but is still queued: %v\n", id, err)
+	}
+	return e, nil
+}
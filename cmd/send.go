@@ -2,39 +2,191 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/emersion/go-imap/v2"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+	"github.com/Softorize/yoy/internal/templates"
 	"github.com/Softorize/yoy/internal/yahoo"
 )
 
 // SendCmd sends a new email.
 type SendCmd struct {
-	To      []string `help:"Recipient email addresses." required:"" sep:","`
-	Subject string   `help:"Email subject." required:""`
-	Body    string   `help:"Email body text." required:""`
+	To      []string `help:"Recipient email addresses." sep:","`
+	Subject string   `help:"Email subject."`
 	Cc      []string `help:"CC recipients." sep:","`
 	Bcc     []string `help:"BCC recipients." sep:","`
+	Attach  []string `help:"Attach a file (repeatable)." type:"existingfile" sep:"none"`
+	At      string   `help:"Queue the message in the outbox to be sent at WHEN (2026-10-20 09:00, 09:00, 2h)." placeholder:"WHEN"`
+
+	Template string   `help:"Fill in the message from the named template in the config directory." placeholder:"NAME"`
+	Var      []string `help:"Set a template variable (repeatable)." placeholder:"KEY=VALUE" sep:"none"`
+	VarsFile string   `help:"Read template variables from a JSON object in FILE." name:"vars-file" type:"existingfile" placeholder:"FILE"`
+
+	bodyFlags `embed:""`
+	htmlFlags `embed:""`
 }
 
-// Run sends the email.
+// Run sends the email, or queues it with --at.
 func (c *SendCmd) Run(ctx *Context) error {
+	if c.Template == "" && (len(c.Var) > 0 || c.VarsFile != "") {
+		return yoyerrors.New("--var and --vars-file need --template", yoyerrors.ExitInvalidInput)
+	}
+
+	var at time.Time
+	if c.At != "" {
+		var err error
+		if at, err = parseSendAt(c.At, time.Now()); err != nil {
+			return err
+		}
+	}
+
 	email, err := ctx.Email()
 	if err != nil {
 		return err
 	}
 
+	msg := &composition{To: c.To, Cc: c.Cc, Subject: c.Subject}
+	bcc := c.Bcc
+	var headers map[string]string
+	if c.Template != "" {
+		t, err := c.render()
+		if err != nil {
+			return err
+		}
+		// Flags override what the template sets.
+		if len(msg.To) == 0 {
+			msg.To = t.To
+		}
+		if len(msg.Cc) == 0 {
+			msg.Cc = t.Cc
+		}
+		if len(bcc) == 0 {
+			bcc = t.Bcc
+		}
+		if msg.Subject == "" {
+			msg.Subject = t.Subject
+		}
+		if c.Body == "" && c.BodyFile == "" {
+			c.Body = t.Body
+		}
+		headers = t.Headers
+	}
+
+	edited, err := c.fill(msg, c.HTML != "")
+	if err != nil {
+		return err
+	}
+
+	if len(msg.To) == 0 {
+		return yoyerrors.New("no recipients", yoyerrors.ExitInvalidInput).
+			WithHint("Pass --to, or fill in the To line in the editor.")
+	}
+	// A blank Subject line is a choice made in the editor; otherwise the
+	// subject is required.
+	if msg.Subject == "" && !edited {
+		return yoyerrors.New("missing --subject", yoyerrors.ExitInvalidInput)
+	}
+
 	opts := &yahoo.SendOptions{
-		From:    email,
-		To:      c.To,
-		Cc:      c.Cc,
-		Bcc:     c.Bcc,
-		Subject: c.Subject,
-		Body:    c.Body,
+		From:        email,
+		To:          msg.To,
+		Cc:          msg.Cc,
+		Bcc:         bcc,
+		Subject:     msg.Subject,
+		Body:        msg.Body,
+		Headers:     headers,
+		Attachments: c.Attach,
+	}
+	if err := c.htmlFlags.apply(opts); err != nil {
+		return err
+	}
+
+	if !at.IsZero() {
+		return queueMail(opts, at)
 	}
 
-	if err := yahoo.SendMail(email, opts); err != nil {
+	if err := sendMail(ctx, email, opts); err != nil {
 		return err
 	}
 
 	fmt.Println("Email sent successfully.")
 	return nil
 }
+
+// render loads --template and renders it with variables from the
+// environment, --vars-file and --var.
+func (c *SendCmd) render() (*templates.Template, error) {
+	t, err := templates.Load(c.Template)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := templates.Vars(os.Environ(), c.VarsFile, c.Var)
+	if err != nil {
+		return nil, err
+	}
+	return t.Render(vars)
+}
+
+// htmlFlags select an HTML version of a message body.
+type htmlFlags struct {
+	Markdown bool   `help:"Render the body as Markdown and send it as HTML, with the text as fallback." xor:"html"`
+	HTML     string `help:"Send the HTML in FILE, with the body (or text rendered from FILE) as fallback." name:"html" type:"existingfile" placeholder:"FILE" xor:"html"`
+}
+
+// apply sets the HTML body of opts from its text body or the HTML file.
+func (f *htmlFlags) apply(opts *yahoo.SendOptions) error {
+	switch {
+	case f.Markdown:
+		opts.HTMLBody = yahoo.MarkdownToHTML(opts.Body)
+	case f.HTML != "":
+		data, err := os.ReadFile(f.HTML)
+		if err != nil {
+			return yoyerrors.Wrap("reading HTML file", err, yoyerrors.ExitInvalidInput)
+		}
+		opts.HTMLBody = string(data)
+		if strings.TrimSpace(opts.Body) == "" {
+			opts.Body = yahoo.HTMLToText(opts.HTMLBody)
+		}
+	}
+	return nil
+}
+
+// sendMail sends a message and keeps a copy of it in Sent.
+func sendMail(ctx *Context, email string, opts *yahoo.SendOptions) error {
+	raw, err := yahoo.SendMail(email, opts)
+	if err != nil {
+		return err
+	}
+	keepSent(ctx, raw)
+	return nil
+}
+
+// keepSent stores the bytes of a sent message in the Sent folder, unless
+// save_sent is off. A failure to store the copy only warns, since the
+// message has already gone out.
+func keepSent(ctx *Context, raw []byte) {
+	if ctx.Config != nil && ctx.Config.SaveSent {
+		if err := saveSent(ctx, raw); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: message sent but not saved to Sent: %v\n", err)
+		}
+	}
+}
+
+// saveSent appends a sent message to the special-use Sent folder, marked as
+// read.
+func saveSent(ctx *Context, raw []byte) error {
+	client, err := ctx.IMAPClient()
+	if err != nil {
+		return err
+	}
+	folder, err := client.SpecialFolder(imap.MailboxAttrSent)
+	if err != nil {
+		return err
+	}
+	_, err = client.AppendMessage(folder, raw, []imap.Flag{imap.FlagSeen})
+	return err
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -11,7 +13,7 @@ import (
 
 // Config holds all yoy configuration.
 type Config struct {
-	// OutputFormat is the default output format (table, json, plain).
+	// OutputFormat is the default output format, one of OutputFormats.
 	OutputFormat string `yaml:"output_format,omitempty"`
 
 	// ColorMode controls color output (auto, always, never).
@@ -23,6 +25,10 @@ type Config struct {
 	// MailLimit is the default number of messages to show.
 	MailLimit int `yaml:"mail_limit,omitempty"`
 
+	// SaveSent stores a copy of sent mail in the Sent folder. Turn it off
+	// for servers that already do.
+	SaveSent bool `yaml:"save_sent"`
+
 	path string `yaml:"-"`
 }
 
@@ -33,6 +39,7 @@ func Load() (*Config, error) {
 		ColorMode:     DefaultColorMode,
 		DefaultFolder: DefaultFolder,
 		MailLimit:     DefaultMailLimit,
+		SaveSent:      DefaultSaveSent,
 		path:          FilePath(),
 	}
 
@@ -84,6 +91,8 @@ func (c *Config) Get(key string) (string, error) {
 		return c.DefaultFolder, nil
 	case "mail_limit":
 		return fmt.Sprintf("%d", c.MailLimit), nil
+	case "save_sent":
+		return strconv.FormatBool(c.SaveSent), nil
 	default:
 		return "", fmt.Errorf("unknown config key: %s", key)
 	}
@@ -93,8 +102,8 @@ func (c *Config) Get(key string) (string, error) {
 func (c *Config) Set(key, value string) error {
 	switch strings.ToLower(key) {
 	case "output_format":
-		if value != "table" && value != "json" && value != "plain" {
-			return fmt.Errorf("invalid output format %q: must be table, json, or plain", value)
+		if !slices.Contains(OutputFormats, value) {
+			return fmt.Errorf("invalid output format %q: must be one of %s", value, strings.Join(OutputFormats, ", "))
 		}
 		c.OutputFormat = value
 	case "color_mode":
@@ -110,6 +119,12 @@ func (c *Config) Set(key, value string) error {
 			return fmt.Errorf("invalid mail limit %q: must be a positive integer", value)
 		}
 		c.MailLimit = limit
+	case "save_sent":
+		save, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid save_sent %q: must be true or false", value)
+		}
+		c.SaveSent = save
 	default:
 		return fmt.Errorf("unknown config key: %s", key)
 	}
@@ -123,6 +138,7 @@ func (c *Config) List() map[string]string {
 		"color_mode":     c.ColorMode,
 		"default_folder": c.DefaultFolder,
 		"mail_limit":     fmt.Sprintf("%d", c.MailLimit),
+		"save_sent":      strconv.FormatBool(c.SaveSent),
 	}
 }
 
@@ -138,6 +154,7 @@ func ValidKeys() []string {
 		"color_mode",
 		"default_folder",
 		"mail_limit",
+		"save_sent",
 	}
 }
 
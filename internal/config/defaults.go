@@ -6,8 +6,12 @@ const (
 	DefaultColorMode    = "auto"
 	DefaultMailLimit    = 25
 	DefaultFolder       = "INBOX"
+	DefaultSaveSent     = true
 	DefaultIMAPHost     = "imap.mail.yahoo.com"
 	DefaultIMAPPort     = 993
 	DefaultSMTPHost     = "smtp.mail.yahoo.com"
 	DefaultSMTPPort     = 465
 )
+
+// OutputFormats lists the accepted values of output_format.
+var OutputFormats = []string{"table", "json", "plain", "csv", "ndjson", "yaml"}
@@ -0,0 +1,273 @@
+// Package outbox spools messages to be sent later. Each queued message is
+// kept in the outbox directory as its composed source (ID.eml) and a JSON
+// record of when to send it and how delivery has gone so far (ID.json).
+package outbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Softorize/yoy/internal/config"
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+// MaxAttempts is the number of failed sends after which a dispatcher stops
+// retrying a message. Flushing still sends it.
+const MaxAttempts = 5
+
+// lockTimeout is the age after which a lock is taken to be left behind by a
+// dispatcher that died while sending.
+const lockTimeout = 15 * time.Minute
+
+// ErrLocked is returned by Lock when another process is sending a message.
+var ErrLocked = errors.New("message is being sent by another process")
+
+// Entry is the record of a queued message.
+type Entry struct {
+	ID        string    `json:"id"`
+	SendAt    time.Time `json:"send_at"`
+	CreatedAt time.Time `json:"created_at"`
+	To        []string  `json:"to"`
+	Subject   string    `json:"subject"`
+
+	Attempts    int       `json:"attempts"`
+	LastAttempt time.Time `json:"last_attempt,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+	// RetryAt delays the next attempt after a failure.
+	RetryAt time.Time `json:"retry_at,omitzero"`
+}
+
+// NextAttempt returns when a dispatcher will next try to send e.
+func (e *Entry) NextAttempt() time.Time {
+	if e.RetryAt.After(e.SendAt) {
+		return e.RetryAt
+	}
+	return e.SendAt
+}
+
+// Due reports whether a dispatcher should send e at now.
+func (e *Entry) Due(now time.Time) bool {
+	return e.Attempts < MaxAttempts && !now.Before(e.NextAttempt())
+}
+
+// Status describes e as scheduled, due, retrying or failed.
+func (e *Entry) Status() string {
+	switch {
+	case e.Attempts >= MaxAttempts:
+		return "failed"
+	case e.Attempts > 0:
+		return "retrying"
+	case time.Now().Before(e.SendAt):
+		return "scheduled"
+	}
+	return "due"
+}
+
+// Queue is an outbox directory.
+type Queue struct {
+	dir string
+}
+
+// Dir returns the outbox directory.
+func Dir() string {
+	return filepath.Join(config.Dir(), "outbox")
+}
+
+// Open opens the outbox, creating its directory if needed.
+func Open() (*Queue, error) {
+	dir := Dir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, yoyerrors.Wrap("creating outbox directory", err, yoyerrors.ExitConfig)
+	}
+	return &Queue{dir: dir}, nil
+}
+
+// Add queues the raw message described by e and sets its ID.
+func (q *Queue) Add(raw []byte, e *Entry) error {
+	for {
+		e.ID = newID()
+		f, err := os.OpenFile(q.path(e.ID, ".eml"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return yoyerrors.Wrap("queueing message", err, yoyerrors.ExitConfig)
+		}
+
+		_, err = f.Write(raw)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = q.save(e)
+		}
+		if err != nil {
+			os.Remove(q.path(e.ID, ".eml"))
+			return yoyerrors.Wrap("queueing message", err, yoyerrors.ExitConfig)
+		}
+		return nil
+	}
+}
+
+// List returns the queued messages in the order they are due.
+func (q *Queue) List() ([]Entry, error) {
+	names, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		return nil, yoyerrors.Wrap("reading outbox", err, yoyerrors.ExitConfig)
+	}
+
+	entries := make([]Entry, 0, len(names))
+	for _, name := range names {
+		e, err := q.Get(strings.TrimSuffix(filepath.Base(name), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].SendAt.Equal(entries[j].SendAt) {
+			return entries[i].SendAt.Before(entries[j].SendAt)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// Get returns the record of a queued message.
+func (q *Queue) Get(id string) (*Entry, error) {
+	if !validID(id) {
+		return nil, notFound(id)
+	}
+	data, err := os.ReadFile(q.path(id, ".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, notFound(id)
+	}
+	if err != nil {
+		return nil, yoyerrors.Wrap("reading outbox", err, yoyerrors.ExitConfig)
+	}
+
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, yoyerrors.Wrap(fmt.Sprintf("reading outbox entry %s", id), err, yoyerrors.ExitConfig)
+	}
+	return &e, nil
+}
+
+// Message returns the source of a queued message.
+func (q *Queue) Message(id string) ([]byte, error) {
+	data, err := os.ReadFile(q.path(id, ".eml"))
+	if err != nil {
+		return nil, yoyerrors.Wrap(fmt.Sprintf("reading queued message %s", id), err, yoyerrors.ExitConfig)
+	}
+	return data, nil
+}
+
+// Remove deletes a queued message. The record goes first, so a message is
+// never listed without its source.
+func (q *Queue) Remove(id string) error {
+	if err := os.Remove(q.path(id, ".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return yoyerrors.Wrap("removing queued message", err, yoyerrors.ExitConfig)
+	}
+	if err := os.Remove(q.path(id, ".eml")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return yoyerrors.Wrap("removing queued message", err, yoyerrors.ExitConfig)
+	}
+	return nil
+}
+
+// Fail records a failed attempt to send e at now, and delays the next one:
+// 1, 4, 16 and then 60 minutes.
+func (q *Queue) Fail(e *Entry, sendErr error, now time.Time) error {
+	e.Attempts++
+	e.LastAttempt = now
+	e.LastError = sendErr.Error()
+
+	delay := time.Minute << (2 * (e.Attempts - 1))
+	if delay > time.Hour || delay <= 0 {
+		delay = time.Hour
+	}
+	e.RetryAt = now.Add(delay)
+
+	if err := q.save(e); err != nil {
+		return yoyerrors.Wrap("recording failed send", err, yoyerrors.ExitConfig)
+	}
+	return nil
+}
+
+// Lock marks a message as being sent, so that a flush and a running
+// dispatcher never send it twice. It returns ErrLocked if another process
+// holds the lock, and a function that releases it otherwise.
+func (q *Queue) Lock(id string) (func(), error) {
+	path := q.path(id, ".lock")
+	for range 2 {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, yoyerrors.Wrap("locking queued message", err, yoyerrors.ExitConfig)
+		}
+
+		info, statErr := os.Stat(path)
+		if statErr != nil || time.Since(info.ModTime()) < lockTimeout {
+			break
+		}
+		os.Remove(path)
+	}
+	return nil, ErrLocked
+}
+
+// save writes the record of e, replacing any previous one in one step.
+func (q *Queue) save(e *Entry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := q.path(e.ID, ".json.tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path(e.ID, ".json"))
+}
+
+// path returns the file of a message with the given extension.
+func (q *Queue) path(id, ext string) string {
+	return filepath.Join(q.dir, id+ext)
+}
+
+// newID returns a random message ID of eight hex digits.
+func newID() string {
+	var b [4]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validID reports whether id could have been made by newID, so that IDs
+// given on the command line cannot point outside the outbox.
+func validID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// notFound is the error for an ID that is not in the outbox.
+func notFound(id string) error {
+	return yoyerrors.New(fmt.Sprintf("no queued message %q", id), yoyerrors.ExitNotFound).
+		WithHint("Use 'yoy outbox list' to see queued messages.")
+}
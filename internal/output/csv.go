@@ -0,0 +1,167 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"iter"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Softorize/yoy/internal/yahoo"
+)
+
+// CSVFormatter outputs data as RFC 4180 CSV with a header row. Message
+// columns are the same for every command so files can be appended to.
+type CSVFormatter struct{}
+
+var csvMessageHeaders = []string{
+	"uid", "folder", "date", "from_name", "from", "to", "cc", "subject",
+	"flags", "size", "attachment_count", "preview", "depth", "thread_count",
+}
+
+func csvMessageRow(m *yahoo.Message) []string {
+	return []string{
+		fmt.Sprintf("%d", m.UID),
+		m.Folder,
+		m.Date.Format(time.RFC3339),
+		m.From.Name,
+		m.From.Address,
+		joinAddresses(m.To),
+		joinAddresses(m.Cc),
+		m.Subject,
+		strings.Join(m.Flags, " "),
+		fmt.Sprintf("%d", m.Size),
+		fmt.Sprintf("%d", m.AttachmentCount),
+		m.Preview,
+		fmt.Sprintf("%d", m.Depth),
+		fmt.Sprintf("%d", m.ThreadCount),
+	}
+}
+
+// joinAddresses lists bare addresses separated by "; ".
+func joinAddresses(addrs []yahoo.Address) string {
+	parts := make([]string, len(addrs))
+	for i, a := range addrs {
+		parts[i] = a.Address
+	}
+	return strings.Join(parts, "; ")
+}
+
+func writeCSV(w io.Writer, headers []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(headers); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func (f *CSVFormatter) FormatMessages(w io.Writer, messages []yahoo.Message) error {
+	rows := make([][]string, len(messages))
+	for i := range messages {
+		rows[i] = csvMessageRow(&messages[i])
+	}
+	return writeCSV(w, csvMessageHeaders, rows)
+}
+
+// StreamMessages writes each message as soon as it arrives. The header row
+// is written with the first row, so nothing is written if there are none.
+func (f *CSVFormatter) StreamMessages(w io.Writer, messages iter.Seq2[yahoo.Message, error], opts StreamOptions) error {
+	cw := csv.NewWriter(w)
+	header := true
+	for m, err := range messages {
+		if err != nil {
+			return err
+		}
+		if header {
+			if err := cw.Write(csvMessageHeaders); err != nil {
+				return err
+			}
+			header = false
+		}
+		if err := cw.Write(csvMessageRow(&m)); err != nil {
+			return err
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *CSVFormatter) FormatMessagePage(w io.Writer, page *yahoo.MessagePage) error {
+	return f.FormatMessages(w, page.Messages)
+}
+
+func (f *CSVFormatter) FormatMessage(w io.Writer, message *yahoo.Message) error {
+	headers := append(append([]string{}, csvMessageHeaders...), "body")
+	row := append(csvMessageRow(message), message.Body)
+	return writeCSV(w, headers, [][]string{row})
+}
+
+func (f *CSVFormatter) FormatFolders(w io.Writer, folders []yahoo.Folder) error {
+	rows := make([][]string, len(folders))
+	for i, folder := range folders {
+		rows[i] = []string{
+			folder.Name,
+			fmt.Sprintf("%d", folder.Messages),
+			fmt.Sprintf("%d", folder.Unseen),
+		}
+	}
+	return writeCSV(w, []string{"name", "messages", "unseen"}, rows)
+}
+
+func (f *CSVFormatter) FormatAttachments(w io.Writer, attachments []yahoo.Attachment) error {
+	rows := make([][]string, len(attachments))
+	for i, a := range attachments {
+		rows[i] = []string{
+			fmt.Sprintf("%d", a.Index),
+			a.Part,
+			a.Filename,
+			a.ContentType,
+			fmt.Sprintf("%d", a.Size),
+		}
+	}
+	return writeCSV(w, []string{"index", "part", "filename", "content_type", "size"}, rows)
+}
+
+func (f *CSVFormatter) FormatKeyValue(w io.Writer, data map[string]string) error {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rows := make([][]string, len(keys))
+	for i, k := range keys {
+		rows[i] = []string{k, data[k]}
+	}
+	return writeCSV(w, []string{"key", "value"}, rows)
+}
+
+func (f *CSVFormatter) FormatOutbox(w io.Writer, entries []OutboxEntry) error {
+	rows := make([][]string, len(entries))
+	for i, e := range entries {
+		lastAttempt := ""
+		if !e.LastAttempt.IsZero() {
+			lastAttempt = e.LastAttempt.Format(time.RFC3339)
+		}
+		rows[i] = []string{
+			e.ID,
+			e.SendAt.Format(time.RFC3339),
+			e.CreatedAt.Format(time.RFC3339),
+			strings.Join(e.To, "; "),
+			e.Subject,
+			e.Status,
+			fmt.Sprintf("%d", e.Attempts),
+			lastAttempt,
+			e.LastError,
+		}
+	}
+	return writeCSV(w, []string{"id", "send_at", "created_at", "to", "subject", "status", "attempts", "last_attempt", "last_error"}, rows)
+}
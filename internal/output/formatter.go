@@ -1,7 +1,11 @@
 package output
 
 import (
+	"fmt"
 	"io"
+	"iter"
+	"strings"
+	"time"
 
 	"github.com/Softorize/yoy/internal/yahoo"
 )
@@ -9,9 +13,130 @@ import (
 // Formatter defines the output formatting interface.
 type Formatter interface {
 	FormatMessages(w io.Writer, messages []yahoo.Message) error
+	FormatMessagePage(w io.Writer, page *yahoo.MessagePage) error
 	FormatMessage(w io.Writer, message *yahoo.Message) error
 	FormatFolders(w io.Writer, folders []yahoo.Folder) error
+	FormatAttachments(w io.Writer, attachments []yahoo.Attachment) error
 	FormatKeyValue(w io.Writer, data map[string]string) error
+	FormatOutbox(w io.Writer, entries []OutboxEntry) error
+}
+
+// MessageStreamer is implemented by formatters that can write messages as
+// they arrive, rather than after the whole listing has been fetched. The
+// stream ends at the first error, which is returned.
+type MessageStreamer interface {
+	StreamMessages(w io.Writer, messages iter.Seq2[yahoo.Message, error], opts StreamOptions) error
+}
+
+// StreamOptions describes a message stream before its first message
+// arrives, so that column headers can be written up front.
+type StreamOptions struct {
+	Previews bool // messages have a Preview
+
+	// NextCursor, if set, gives the cursor of the next page once the stream
+	// has ended, or zero on the last page.
+	NextCursor func() uint32
+}
+
+// WritesCursor reports whether f writes the next cursor of a message page
+// along with its messages. The other formats have no place for it, so the
+// caller reports it separately.
+func WritesCursor(f Formatter) bool {
+	switch f.(type) {
+	case *TableFormatter, *JSONFormatter, *YAMLFormatter, *NDJSONFormatter:
+		return true
+	}
+	return false
+}
+
+// OutboxEntry is a message queued in the outbox, as listed.
+type OutboxEntry struct {
+	ID        string    `json:"id"`
+	SendAt    time.Time `json:"send_at"`
+	CreatedAt time.Time `json:"created_at"`
+	To        []string  `json:"to"`
+	Subject   string    `json:"subject"`
+
+	Attempts    int       `json:"attempts"`
+	LastAttempt time.Time `json:"last_attempt,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+	RetryAt     time.Time `json:"retry_at,omitzero"`
+	Status      string    `json:"status"` // scheduled, due, retrying or failed
+}
+
+// WritesEmptyPage reports whether f writes a message page with no messages
+// as a document, rather than leaving the caller to say there are none.
+func WritesEmptyPage(f Formatter) bool {
+	switch f.(type) {
+	case *JSONFormatter, *YAMLFormatter:
+		return true
+	}
+	return false
+}
+
+// nonNilPage returns page with an empty message list instead of a nil one,
+// so that it is written as [] rather than null.
+func nonNilPage(page *yahoo.MessagePage) *yahoo.MessagePage {
+	if page.Messages != nil {
+		return page
+	}
+	p := *page
+	p.Messages = []yahoo.Message{}
+	return &p
+}
+
+// hasFolders reports whether messages come from a multi-folder search and
+// should be listed with their folder.
+func hasFolders(messages []yahoo.Message) bool {
+	for _, m := range messages {
+		if m.Folder != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPreviews reports whether messages were listed with --preview.
+func hasPreviews(messages []yahoo.Message) bool {
+	for _, m := range messages {
+		if m.Preview != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// hasThreads reports whether messages stand for collapsed conversations.
+func hasThreads(messages []yahoo.Message) bool {
+	for _, m := range messages {
+		if m.ThreadCount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// threadSubject indents the subject of a message in a conversation view by
+// its depth.
+func threadSubject(m *yahoo.Message) string {
+	if m.Depth == 0 {
+		return m.Subject
+	}
+	return strings.Repeat("  ", m.Depth-1) + "└ " + m.Subject
+}
+
+// formatSize renders a byte count in human-readable units.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
 }
 
 // NewFormatter creates a formatter based on format type.
@@ -21,6 +146,12 @@ func NewFormatter(format string, colorEnabled bool) Formatter {
 		return &JSONFormatter{}
 	case "plain":
 		return &PlainFormatter{}
+	case "csv":
+		return &CSVFormatter{}
+	case "ndjson":
+		return &NDJSONFormatter{}
+	case "yaml":
+		return &YAMLFormatter{}
 	default:
 		return &TableFormatter{color: colorEnabled}
 	}
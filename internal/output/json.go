@@ -24,6 +24,10 @@ func (f *JSONFormatter) FormatMessages(w io.Writer, messages []yahoo.Message) er
 	return writeJSON(w, messages)
 }
 
+func (f *JSONFormatter) FormatMessagePage(w io.Writer, page *yahoo.MessagePage) error {
+	return writeJSON(w, nonNilPage(page))
+}
+
 func (f *JSONFormatter) FormatMessage(w io.Writer, message *yahoo.Message) error {
 	return writeJSON(w, message)
 }
@@ -32,6 +36,14 @@ func (f *JSONFormatter) FormatFolders(w io.Writer, folders []yahoo.Folder) error
 	return writeJSON(w, folders)
 }
 
+func (f *JSONFormatter) FormatAttachments(w io.Writer, attachments []yahoo.Attachment) error {
+	return writeJSON(w, attachments)
+}
+
 func (f *JSONFormatter) FormatKeyValue(w io.Writer, data map[string]string) error {
 	return writeJSON(w, data)
 }
+
+func (f *JSONFormatter) FormatOutbox(w io.Writer, entries []OutboxEntry) error {
+	return writeJSON(w, entries)
+}
@@ -0,0 +1,118 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"sort"
+
+	"github.com/Softorize/yoy/internal/yahoo"
+)
+
+// NDJSONFormatter outputs one compact JSON object per line, so output can be
+// processed as it arrives.
+type NDJSONFormatter struct{}
+
+func writeNDJSON(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("json marshal: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func (f *NDJSONFormatter) FormatMessages(w io.Writer, messages []yahoo.Message) error {
+	for i := range messages {
+		if err := writeNDJSON(w, &messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamMessages writes each message as soon as it arrives, followed by a
+// cursor record if there is a next page.
+func (f *NDJSONFormatter) StreamMessages(w io.Writer, messages iter.Seq2[yahoo.Message, error], opts StreamOptions) error {
+	for m, err := range messages {
+		if err != nil {
+			return err
+		}
+		if err := writeNDJSON(w, &m); err != nil {
+			return err
+		}
+	}
+	if opts.NextCursor == nil {
+		return nil
+	}
+	return writeNDJSONCursor(w, opts.NextCursor())
+}
+
+func (f *NDJSONFormatter) FormatMessagePage(w io.Writer, page *yahoo.MessagePage) error {
+	if err := f.FormatMessages(w, page.Messages); err != nil {
+		return err
+	}
+	return writeNDJSONCursor(w, page.NextCursor)
+}
+
+// writeNDJSONCursor ends a page with a {"next_cursor": N} record, unless
+// cursor is zero.
+func writeNDJSONCursor(w io.Writer, cursor uint32) error {
+	if cursor == 0 {
+		return nil
+	}
+	return writeNDJSON(w, struct {
+		NextCursor uint32 `json:"next_cursor"`
+	}{cursor})
+}
+
+func (f *NDJSONFormatter) FormatMessage(w io.Writer, message *yahoo.Message) error {
+	return writeNDJSON(w, message)
+}
+
+func (f *NDJSONFormatter) FormatFolders(w io.Writer, folders []yahoo.Folder) error {
+	for i := range folders {
+		if err := writeNDJSON(w, &folders[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *NDJSONFormatter) FormatAttachments(w io.Writer, attachments []yahoo.Attachment) error {
+	for i := range attachments {
+		if err := writeNDJSON(w, &attachments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *NDJSONFormatter) FormatKeyValue(w io.Writer, data map[string]string) error {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		entry := struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}{k, data[k]}
+		if err := writeNDJSON(w, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *NDJSONFormatter) FormatOutbox(w io.Writer, entries []OutboxEntry) error {
+	for _, e := range entries {
+		if err := writeNDJSON(w, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
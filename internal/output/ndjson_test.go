@@ -0,0 +1,41 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Softorize/yoy/internal/yahoo"
+)
+
+func TestNDJSONStreamEndsWithCursor(t *testing.T) {
+	messages := func(yield func(yahoo.Message, error) bool) {
+		_ = yield(yahoo.Message{UID: 9}, nil) && yield(yahoo.Message{UID: 7}, nil)
+	}
+	tests := []struct {
+		name   string
+		cursor func() uint32
+		want   int // lines
+		last   string
+	}{
+		{"next page", func() uint32 { return 7 }, 3, `{"next_cursor":7}`},
+		{"last page", func() uint32 { return 0 }, 2, ""},
+		{"no cursor", nil, 2, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			f := &NDJSONFormatter{}
+			opts := StreamOptions{NextCursor: tt.cursor}
+			if err := f.StreamMessages(&b, messages, opts); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+			if len(lines) != tt.want {
+				t.Fatalf("got %d lines, want %d:\n%s", len(lines), tt.want, b.String())
+			}
+			if tt.last != "" && lines[len(lines)-1] != tt.last {
+				t.Errorf("last line = %q, want %q", lines[len(lines)-1], tt.last)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package output
 import (
 	"fmt"
 	"io"
+	"iter"
 	"strings"
 
 	"github.com/Softorize/yoy/internal/yahoo"
@@ -11,9 +12,12 @@ import (
 // PlainFormatter outputs data as tab-separated values.
 type PlainFormatter struct{}
 
+// writeTSV writes a header line, unless headers is nil, and the rows.
 func writeTSV(w io.Writer, headers []string, rows [][]string) error {
-	if _, err := fmt.Fprintln(w, strings.Join(headers, "\t")); err != nil {
-		return err
+	if headers != nil {
+		if _, err := fmt.Fprintln(w, strings.Join(headers, "\t")); err != nil {
+			return err
+		}
 	}
 	for _, row := range rows {
 		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
@@ -24,21 +28,87 @@ func writeTSV(w io.Writer, headers []string, rows [][]string) error {
 }
 
 func (f *PlainFormatter) FormatMessages(w io.Writer, messages []yahoo.Message) error {
+	cols := plainColumns{
+		folder:  hasFolders(messages),
+		threads: hasThreads(messages),
+		preview: hasPreviews(messages),
+	}
 	rows := make([][]string, len(messages))
-	for i, m := range messages {
-		from := m.From.Address
-		if m.From.Name != "" {
-			from = m.From.Name
+	for i := range messages {
+		rows[i] = cols.row(&messages[i])
+	}
+	return writeTSV(w, cols.headers(), rows)
+}
+
+// StreamMessages writes each message as soon as it arrives. The header is
+// written with the first row, so nothing is written if there are none.
+func (f *PlainFormatter) StreamMessages(w io.Writer, messages iter.Seq2[yahoo.Message, error], opts StreamOptions) error {
+	cols := plainColumns{preview: opts.Previews}
+	headers := cols.headers()
+	for m, err := range messages {
+		if err != nil {
+			return err
 		}
-		rows[i] = []string{
-			fmt.Sprintf("%d", m.UID),
-			m.Date.Format("2006-01-02 15:04"),
-			from,
-			m.Subject,
-			strings.Join(m.Flags, ","),
+		if headers != nil {
+			if err := writeTSV(w, headers, nil); err != nil {
+				return err
+			}
+			headers = nil
+		}
+		if err := writeTSV(w, nil, [][]string{cols.row(&m)}); err != nil {
+			return err
 		}
 	}
-	return writeTSV(w, []string{"UID", "Date", "From", "Subject", "Flags"}, rows)
+	return nil
+}
+
+// plainColumns selects the optional columns of a message listing.
+type plainColumns struct {
+	folder, threads, preview bool
+}
+
+func (c plainColumns) headers() []string {
+	headers := []string{"UID", "Date", "From", "Subject", "Flags", "Size", "Attachments"}
+	if c.folder {
+		headers = append([]string{"Folder"}, headers...)
+	}
+	if c.threads {
+		headers = append(headers, "Messages")
+	}
+	if c.preview {
+		headers = append(headers, "Preview")
+	}
+	return headers
+}
+
+func (c plainColumns) row(m *yahoo.Message) []string {
+	from := m.From.Address
+	if m.From.Name != "" {
+		from = m.From.Name
+	}
+	row := []string{
+		fmt.Sprintf("%d", m.UID),
+		m.Date.Format("2006-01-02 15:04"),
+		from,
+		threadSubject(m),
+		strings.Join(m.Flags, ","),
+		fmt.Sprintf("%d", m.Size),
+		fmt.Sprintf("%d", m.AttachmentCount),
+	}
+	if c.folder {
+		row = append([]string{m.Folder}, row...)
+	}
+	if c.threads {
+		row = append(row, fmt.Sprintf("%d", m.ThreadCount))
+	}
+	if c.preview {
+		row = append(row, m.Preview)
+	}
+	return row
+}
+
+func (f *PlainFormatter) FormatMessagePage(w io.Writer, page *yahoo.MessagePage) error {
+	return f.FormatMessages(w, page.Messages)
 }
 
 func (f *PlainFormatter) FormatMessage(w io.Writer, message *yahoo.Message) error {
@@ -47,9 +117,13 @@ func (f *PlainFormatter) FormatMessage(w io.Writer, message *yahoo.Message) erro
 		from = fmt.Sprintf("%s <%s>", message.From.Name, message.From.Address)
 	}
 	fmt.Fprintf(w, "UID\t%d\n", message.UID)
+	if message.Folder != "" {
+		fmt.Fprintf(w, "Folder\t%s\n", message.Folder)
+	}
 	fmt.Fprintf(w, "Date\t%s\n", message.Date.Format("2006-01-02 15:04:05 -0700"))
 	fmt.Fprintf(w, "From\t%s\n", from)
 	fmt.Fprintf(w, "Subject\t%s\n", message.Subject)
+	fmt.Fprintf(w, "Size\t%d\n", message.Size)
 	fmt.Fprintln(w, "")
 	fmt.Fprintln(w, message.Body)
 	return nil
@@ -67,6 +141,20 @@ func (f *PlainFormatter) FormatFolders(w io.Writer, folders []yahoo.Folder) erro
 	return writeTSV(w, []string{"Name", "Messages", "Unseen"}, rows)
 }
 
+func (f *PlainFormatter) FormatAttachments(w io.Writer, attachments []yahoo.Attachment) error {
+	rows := make([][]string, len(attachments))
+	for i, a := range attachments {
+		rows[i] = []string{
+			fmt.Sprintf("%d", a.Index),
+			a.Part,
+			a.Filename,
+			a.ContentType,
+			fmt.Sprintf("%d", a.Size),
+		}
+	}
+	return writeTSV(w, []string{"Index", "Part", "Filename", "Type", "Size"}, rows)
+}
+
 func (f *PlainFormatter) FormatKeyValue(w io.Writer, data map[string]string) error {
 	for key, val := range data {
 		if _, err := fmt.Fprintf(w, "%s\t%s\n", key, val); err != nil {
@@ -75,3 +163,19 @@ func (f *PlainFormatter) FormatKeyValue(w io.Writer, data map[string]string) err
 	}
 	return nil
 }
+
+func (f *PlainFormatter) FormatOutbox(w io.Writer, entries []OutboxEntry) error {
+	rows := make([][]string, len(entries))
+	for i, e := range entries {
+		rows[i] = []string{
+			e.ID,
+			e.SendAt.Local().Format("2006-01-02 15:04"),
+			strings.Join(e.To, ","),
+			e.Subject,
+			e.Status,
+			fmt.Sprintf("%d", e.Attempts),
+			e.LastError,
+		}
+	}
+	return writeTSV(w, []string{"ID", "SendAt", "To", "Subject", "Status", "Attempts", "Error"}, rows)
+}
@@ -40,21 +40,53 @@ func (f *TableFormatter) newTable(w io.Writer, headers []string) *tablewriter.Ta
 }
 
 func (f *TableFormatter) FormatMessages(w io.Writer, messages []yahoo.Message) error {
-	table := f.newTable(w, []string{"UID", "Date", "From", "Subject", "Flags"})
+	headers := []string{"UID", "Date", "From", "Subject", "Flags", "Size", "Att"}
+	withFolder := hasFolders(messages)
+	if withFolder {
+		headers = append([]string{"Folder"}, headers...)
+	}
+	withThreads := hasThreads(messages)
+	if withThreads {
+		headers = append(headers, "Msgs")
+	}
+	withPreview := hasPreviews(messages)
+	if withPreview {
+		headers = append(headers, "Preview")
+	}
+
+	table := f.newTable(w, headers)
 	for _, m := range messages {
 		from := m.From.Address
 		if m.From.Name != "" {
 			from = m.From.Name
 		}
 		// Truncate subject to 50 chars.
-		subject := m.Subject
+		subject := threadSubject(&m)
 		if len(subject) > 50 {
 			subject = subject[:47] + "..."
 		}
 		flags := strings.Join(m.Flags, ",")
 
 		uid := fmt.Sprintf("%d", m.UID)
-		row := []string{uid, m.Date.Format("2006-01-02 15:04"), from, subject, flags}
+		attachments := ""
+		if m.HasAttachments {
+			attachments = fmt.Sprintf("%d", m.AttachmentCount)
+		}
+		row := []string{uid, m.Date.Format("2006-01-02 15:04"), from, subject, flags, formatSize(m.Size), attachments}
+		if withFolder {
+			row = append([]string{m.Folder}, row...)
+		}
+		if withThreads {
+			row = append(row, fmt.Sprintf("%d", m.ThreadCount))
+		}
+		if withPreview {
+			// Truncate preview to 60 chars.
+			preview := []rune(m.Preview)
+			if len(preview) > 60 {
+				preview = append(preview[:57], []rune("...")...)
+			}
+			row = append(row, string(preview))
+		}
 
 		if f.color && !m.Seen {
 			for i := range row {
@@ -68,6 +100,16 @@ func (f *TableFormatter) FormatMessages(w io.Writer, messages []yahoo.Message) e
 	return nil
 }
 
+func (f *TableFormatter) FormatMessagePage(w io.Writer, page *yahoo.MessagePage) error {
+	if err := f.FormatMessages(w, page.Messages); err != nil {
+		return err
+	}
+	if page.NextCursor != 0 {
+		fmt.Fprintf(w, "\nNext cursor: %d\n", page.NextCursor)
+	}
+	return nil
+}
+
 func (f *TableFormatter) FormatMessage(w io.Writer, message *yahoo.Message) error {
 	from := message.From.Address
 	if message.From.Name != "" {
@@ -84,6 +126,9 @@ func (f *TableFormatter) FormatMessage(w io.Writer, message *yahoo.Message) erro
 	}
 
 	fmt.Fprintf(w, "UID:     %d\n", message.UID)
+	if message.Folder != "" {
+		fmt.Fprintf(w, "Folder:  %s\n", message.Folder)
+	}
 	fmt.Fprintf(w, "Date:    %s\n", message.Date.Format("2006-01-02 15:04:05 -0700"))
 	fmt.Fprintf(w, "From:    %s\n", from)
 	fmt.Fprintf(w, "To:      %s\n", strings.Join(toAddrs, ", "))
@@ -102,6 +147,9 @@ func (f *TableFormatter) FormatMessage(w io.Writer, message *yahoo.Message) erro
 	if len(message.Flags) > 0 {
 		fmt.Fprintf(w, "Flags:   %s\n", strings.Join(message.Flags, ", "))
 	}
+	if message.Size > 0 {
+		fmt.Fprintf(w, "Size:    %s\n", formatSize(message.Size))
+	}
 	if len(message.Attachments) > 0 {
 		names := make([]string, len(message.Attachments))
 		for i, a := range message.Attachments {
@@ -127,6 +175,21 @@ func (f *TableFormatter) FormatFolders(w io.Writer, folders []yahoo.Folder) erro
 	return nil
 }
 
+func (f *TableFormatter) FormatAttachments(w io.Writer, attachments []yahoo.Attachment) error {
+	table := f.newTable(w, []string{"#", "Part", "Filename", "Type", "Size"})
+	for _, a := range attachments {
+		table.Append([]string{
+			fmt.Sprintf("%d", a.Index),
+			a.Part,
+			a.Filename,
+			a.ContentType,
+			formatSize(int64(a.Size)),
+		})
+	}
+	table.Render()
+	return nil
+}
+
 func (f *TableFormatter) FormatKeyValue(w io.Writer, data map[string]string) error {
 	table := f.newTable(w, []string{"Field", "Value"})
 	for key, val := range data {
@@ -139,3 +202,31 @@ func (f *TableFormatter) FormatKeyValue(w io.Writer, data map[string]string) err
 	table.Render()
 	return nil
 }
+
+func (f *TableFormatter) FormatOutbox(w io.Writer, entries []OutboxEntry) error {
+	table := f.newTable(w, []string{"ID", "Send At", "To", "Subject", "Status", "Error"})
+	for _, e := range entries {
+		subject := e.Subject
+		if len(subject) > 50 {
+			subject = subject[:47] + "..."
+		}
+		lastError := e.LastError
+		if len(lastError) > 60 {
+			lastError = lastError[:57] + "..."
+		}
+		status := e.Status
+		if e.Attempts > 0 {
+			status = fmt.Sprintf("%s (%d)", status, e.Attempts)
+		}
+		table.Append([]string{
+			e.ID,
+			e.SendAt.Local().Format("2006-01-02 15:04"),
+			strings.Join(e.To, ", "),
+			subject,
+			status,
+			lastError,
+		})
+	}
+	table.Render()
+	return nil
+}
@@ -0,0 +1,234 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+	"github.com/Softorize/yoy/internal/yahoo"
+)
+
+// TemplateFormatter renders each item with a user-supplied Go text/template.
+// Messages, folders and attachments are passed as their yahoo types; key/value
+// data as a KeyValue per entry.
+type TemplateFormatter struct {
+	tmpl *template.Template
+	err  error
+}
+
+// KeyValue is one entry of key/value output, as seen by a template.
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// templateEscapes turns the escapes people type on the command line into
+// the characters they mean.
+var templateEscapes = strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\\`, `\`)
+
+// expandEscapes applies templateEscapes to the text outside {{ }} actions,
+// leaving string literals in actions to the template parser.
+func expandEscapes(text string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			b.WriteString(templateEscapes.Replace(text))
+			return b.String()
+		}
+		b.WriteString(templateEscapes.Replace(text[:start]))
+		end := actionEnd(text[start:])
+		b.WriteString(text[start : start+end])
+		text = text[start+end:]
+	}
+}
+
+// actionEnd returns the length of the action at the start of s, through its
+// closing "}}", skipping quoted strings, characters and comments. An
+// unterminated action runs to the end of s.
+func actionEnd(s string) int {
+	i := 2
+	for i < len(s) {
+		switch {
+		case strings.HasPrefix(s[i:], "}}"):
+			return i + 2
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return len(s)
+			}
+			i += 2 + end + 2
+		case s[i] == '"' || s[i] == '\'' || s[i] == '`':
+			quote := s[i]
+			i++
+			for i < len(s) && s[i] != quote {
+				if s[i] == '\\' && quote != '`' {
+					i++
+				}
+				i++
+			}
+			i++
+		default:
+			i++
+		}
+	}
+	return len(s)
+}
+
+// templateFuncs are the helper functions available in templates.
+var templateFuncs = template.FuncMap{
+	// date formats a time with a Go layout, or one of "rfc3339", "date",
+	// "datetime" and "unix".
+	"date": func(layout string, t time.Time) string {
+		switch layout {
+		case "rfc3339":
+			return t.Format(time.RFC3339)
+		case "date":
+			return t.Format("2006-01-02")
+		case "datetime":
+			return t.Format("2006-01-02 15:04")
+		case "unix":
+			return fmt.Sprintf("%d", t.Unix())
+		}
+		return t.Format(layout)
+	},
+	// truncate shortens s to n characters, ending in "..." if cut.
+	"truncate": func(n int, s string) string {
+		runes := []rune(s)
+		if len(runes) <= n {
+			return s
+		}
+		if n <= 3 {
+			return string(runes[:n])
+		}
+		return string(runes[:n-3]) + "..."
+	},
+	// address renders an address as "Name <addr>", or just the address.
+	"address": formatAddress,
+	// addresses renders a list of addresses separated by commas.
+	"addresses": func(addrs []yahoo.Address) string {
+		parts := make([]string, len(addrs))
+		for i, a := range addrs {
+			parts[i] = formatAddress(a)
+		}
+		return strings.Join(parts, ", ")
+	},
+	"join":  func(sep string, s []string) string { return strings.Join(s, sep) },
+	"size":  formatSize,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// NewTemplateFormatter creates a formatter from template text. Parse errors
+// are reported when formatting.
+func NewTemplateFormatter(text string) *TemplateFormatter {
+	tmpl, err := template.New("format").Funcs(templateFuncs).Parse(expandEscapes(text))
+	if err != nil {
+		err = yoyerrors.Wrap("invalid --format template", err, yoyerrors.ExitInvalidInput)
+	}
+	return &TemplateFormatter{tmpl: tmpl, err: err}
+}
+
+// execute renders one item followed by a newline.
+func (f *TemplateFormatter) execute(w io.Writer, data any) error {
+	if f.err != nil {
+		return f.err
+	}
+	var b strings.Builder
+	if err := f.tmpl.Execute(&b, data); err != nil {
+		return yoyerrors.Wrap("executing --format template", err, yoyerrors.ExitInvalidInput)
+	}
+	if !strings.HasSuffix(b.String(), "\n") {
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (f *TemplateFormatter) FormatMessages(w io.Writer, messages []yahoo.Message) error {
+	for i := range messages {
+		if err := f.execute(w, &messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamMessages renders each message as soon as it arrives.
+func (f *TemplateFormatter) StreamMessages(w io.Writer, messages iter.Seq2[yahoo.Message, error], opts StreamOptions) error {
+	if f.err != nil {
+		return f.err
+	}
+	for m, err := range messages {
+		if err != nil {
+			return err
+		}
+		if err := f.execute(w, &m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *TemplateFormatter) FormatMessagePage(w io.Writer, page *yahoo.MessagePage) error {
+	return f.FormatMessages(w, page.Messages)
+}
+
+func (f *TemplateFormatter) FormatMessage(w io.Writer, message *yahoo.Message) error {
+	return f.execute(w, message)
+}
+
+func (f *TemplateFormatter) FormatFolders(w io.Writer, folders []yahoo.Folder) error {
+	for i := range folders {
+		if err := f.execute(w, &folders[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *TemplateFormatter) FormatAttachments(w io.Writer, attachments []yahoo.Attachment) error {
+	for i := range attachments {
+		if err := f.execute(w, &attachments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *TemplateFormatter) FormatKeyValue(w io.Writer, data map[string]string) error {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if err := f.execute(w, KeyValue{Key: k, Value: data[k]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *TemplateFormatter) FormatOutbox(w io.Writer, entries []OutboxEntry) error {
+	for i := range entries {
+		if err := f.execute(w, &entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatAddress renders an address as "Name <addr>", or just the address.
+func formatAddress(a yahoo.Address) string {
+	if a.Name != "" {
+		return fmt.Sprintf("%s <%s>", a.Name, a.Address)
+	}
+	return a.Address
+}
@@ -0,0 +1,37 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplateEscapesOnlyOutsideActions(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{`{{.Key}}\t{{.Value}}`, "k\tv\n"},
+		{`{{.Key}}\n`, "k\n"},
+		{`{{join "\n" .List}}`, "a\nb\n"},
+		{`{{join "\\n" .List}}|`, `a\nb|` + "\n"},
+		{"{{join `\\t` .List}}", `a\tb` + "\n"},
+		{`{{printf "%s}}" .Key}}\t.`, "k}}\t.\n"},
+		{`{{/* \t */}}a\\b`, `a\b` + "\n"},
+	}
+	data := struct {
+		Key, Value string
+		List       []string
+	}{"k", "v", []string{"a", "b"}}
+
+	for _, tt := range tests {
+		f := NewTemplateFormatter(tt.format)
+		var b strings.Builder
+		if err := f.execute(&b, data); err != nil {
+			t.Errorf("%s: %v", tt.format, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s = %q, want %q", tt.format, b.String(), tt.want)
+		}
+	}
+}
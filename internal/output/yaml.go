@@ -0,0 +1,75 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Softorize/yoy/internal/yahoo"
+)
+
+// YAMLFormatter outputs data as YAML, with the same fields and shapes as the
+// JSON output.
+type YAMLFormatter struct{}
+
+// writeYAML converts v through JSON so that field names and omitted fields
+// follow the json struct tags.
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("json marshal: %w", err)
+	}
+
+	// JSON is valid YAML; parsing it keeps the key order.
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("yaml convert: %w", err)
+	}
+	clearStyle(&node)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return fmt.Errorf("yaml marshal: %w", err)
+	}
+	return enc.Close()
+}
+
+// clearStyle switches the flow style and quoting of parsed JSON to plain
+// block style. Strings that would read as another type stay quoted.
+func clearStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		clearStyle(c)
+	}
+}
+
+func (f *YAMLFormatter) FormatMessages(w io.Writer, messages []yahoo.Message) error {
+	return writeYAML(w, messages)
+}
+
+func (f *YAMLFormatter) FormatMessagePage(w io.Writer, page *yahoo.MessagePage) error {
+	return writeYAML(w, nonNilPage(page))
+}
+
+func (f *YAMLFormatter) FormatMessage(w io.Writer, message *yahoo.Message) error {
+	return writeYAML(w, message)
+}
+
+func (f *YAMLFormatter) FormatFolders(w io.Writer, folders []yahoo.Folder) error {
+	return writeYAML(w, folders)
+}
+
+func (f *YAMLFormatter) FormatAttachments(w io.Writer, attachments []yahoo.Attachment) error {
+	return writeYAML(w, attachments)
+}
+
+func (f *YAMLFormatter) FormatKeyValue(w io.Writer, data map[string]string) error {
+	return writeYAML(w, data)
+}
+
+func (f *YAMLFormatter) FormatOutbox(w io.Writer, entries []OutboxEntry) error {
+	return writeYAML(w, entries)
+}
@@ -0,0 +1,233 @@
+// Package templates loads named message templates from the config
+// directory. A template is a YAML file whose subject, body, recipients and
+// header values are Go text/templates, filled in from variables.
+package templates
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Softorize/yoy/internal/config"
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+// Template is a message template. Every field is rendered with the same
+// variables.
+type Template struct {
+	Name    string            `yaml:"-"`
+	To      []string          `yaml:"to,omitempty"`
+	Cc      []string          `yaml:"cc,omitempty"`
+	Bcc     []string          `yaml:"bcc,omitempty"`
+	Subject string            `yaml:"subject,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+}
+
+// funcs are the helper functions available in templates.
+var funcs = template.FuncMap{
+	// now returns the current time, for use with date.
+	"now": time.Now,
+	// date formats a time with a Go layout.
+	"date": func(layout string, t time.Time) string { return t.Format(layout) },
+	// env returns an environment variable, or "" if it is unset.
+	"env":   os.Getenv,
+	"join":  func(sep string, s []string) string { return strings.Join(s, sep) },
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// Dir returns the directory templates are loaded from.
+func Dir() string {
+	return filepath.Join(config.Dir(), "templates")
+}
+
+// Load reads the template NAME.yaml (or NAME.yml) from Dir.
+func Load(name string) (*Template, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, yoyerrors.New(fmt.Sprintf("invalid template name %q", name), yoyerrors.ExitInvalidInput)
+	}
+
+	var data []byte
+	var err error
+	for _, ext := range []string{".yaml", ".yml"} {
+		data, err = os.ReadFile(filepath.Join(Dir(), name+ext))
+		if !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		hint := fmt.Sprintf("Templates are read from %s.", Dir())
+		if names, _ := List(); len(names) > 0 {
+			hint = fmt.Sprintf("Available templates: %s.", strings.Join(names, ", "))
+		}
+		return nil, yoyerrors.New(fmt.Sprintf("template %q not found", name), yoyerrors.ExitNotFound).WithHint(hint)
+	}
+	if err != nil {
+		return nil, yoyerrors.Wrap("reading template", err, yoyerrors.ExitConfig)
+	}
+
+	t := &Template{Name: name}
+	if err := yaml.Unmarshal(data, t); err != nil {
+		return nil, yoyerrors.Wrap(fmt.Sprintf("parsing template %q", name), err, yoyerrors.ExitConfig)
+	}
+	for k := range t.Headers {
+		if !validHeader(k) {
+			return nil, yoyerrors.New(fmt.Sprintf("template %q has invalid header name %q", name, k), yoyerrors.ExitConfig)
+		}
+		if reservedHeader(k) {
+			return nil, yoyerrors.New(fmt.Sprintf("template %q sets reserved header %q", name, k), yoyerrors.ExitConfig).
+				WithHint("Set recipients and the subject with the to, cc, bcc and subject fields; headers describing the message itself are set when it is composed.")
+		}
+	}
+	return t, nil
+}
+
+// validHeader reports whether key is a valid header field name: printable
+// ASCII without spaces or colons.
+func validHeader(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] > '~' || key[i] == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// reservedHeader reports whether a template may not set header key: the
+// sender and recipient fields, which must match the SMTP envelope, and the
+// fields that identify or structure the composed message.
+func reservedHeader(key string) bool {
+	key = strings.ToLower(key)
+	switch key {
+	case "from", "sender", "to", "cc", "bcc", "subject", "date", "message-id",
+		"in-reply-to", "references", "mime-version", "return-path":
+		return true
+	}
+	return strings.HasPrefix(key, "content-") || strings.HasPrefix(key, "resent-")
+}
+
+// List returns the names of the available templates, sorted.
+func List() ([]string, error) {
+	entries, err := os.ReadDir(Dir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			names = append(names, strings.TrimSuffix(e.Name(), ext))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Vars merges template variables from the environment, a JSON file holding
+// an object (if file is not empty) and key=value pairs, each overriding the
+// ones before.
+func Vars(environ []string, file string, pairs []string) (map[string]any, error) {
+	vars := map[string]any{}
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok && k != "" {
+			vars[k] = v
+		}
+	}
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, yoyerrors.Wrap("reading variables file", err, yoyerrors.ExitInvalidInput)
+		}
+		var fileVars map[string]any
+		if err := json.Unmarshal(data, &fileVars); err != nil {
+			return nil, yoyerrors.Wrap("parsing variables file", err, yoyerrors.ExitInvalidInput).
+				WithHint("The file must hold a JSON object, e.g. {\"week\": 42}.")
+		}
+		maps.Copy(vars, fileVars)
+	}
+
+	for _, kv := range pairs {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, yoyerrors.New(fmt.Sprintf("invalid --var %q", kv), yoyerrors.ExitInvalidInput).
+				WithHint("Use --var key=value.")
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// Render returns a copy of t with every field rendered with vars. Referring
+// to a variable that is not set is an error.
+func (t *Template) Render(vars map[string]any) (*Template, error) {
+	out := &Template{Name: t.Name}
+	var err error
+
+	render := func(field, text string) string {
+		if err != nil || !strings.Contains(text, "{{") {
+			return text
+		}
+		var tmpl *template.Template
+		tmpl, err = template.New(field).Funcs(funcs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return ""
+		}
+		var b strings.Builder
+		err = tmpl.Execute(&b, vars)
+		return b.String()
+	}
+	renderList := func(field string, list []string) []string {
+		var out []string
+		for _, s := range list {
+			// A rendered entry may hold several comma-separated addresses,
+			// or none.
+			for _, addr := range strings.Split(render(field, s), ",") {
+				if addr = strings.TrimSpace(addr); addr != "" {
+					out = append(out, addr)
+				}
+			}
+		}
+		return out
+	}
+
+	out.To = renderList("to", t.To)
+	out.Cc = renderList("cc", t.Cc)
+	out.Bcc = renderList("bcc", t.Bcc)
+	out.Subject = oneLine(render("subject", t.Subject))
+	out.Body = render("body", t.Body)
+	if len(t.Headers) > 0 {
+		out.Headers = make(map[string]string, len(t.Headers))
+		for k, v := range t.Headers {
+			out.Headers[k] = oneLine(render("headers."+k, v))
+		}
+	}
+
+	if err != nil {
+		return nil, yoyerrors.Wrap(fmt.Sprintf("rendering template %q", t.Name), err, yoyerrors.ExitInvalidInput).
+			WithHint("Set missing variables with --var key=value, --vars-file or the environment.")
+	}
+	return out, nil
+}
+
+// oneLine trims a rendered header value and joins its lines.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,228 @@
+package yahoo
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/emersion/go-message"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+// ListAttachments returns the attachments of a message. Only the message's
+// BODYSTRUCTURE is fetched, not its content.
+func (ic *IMAPClient) ListAttachments(folder string, uid uint32) ([]Attachment, error) {
+	if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
+		return nil, yoyerrors.FromIMAPError(err)
+	}
+
+	uidSet := imap.UIDSetNum(imap.UID(uid))
+
+	fetchOptions := &imap.FetchOptions{
+		UID:           true,
+		BodyStructure: &imap.FetchItemBodyStructure{Extended: true},
+	}
+
+	msgs, err := ic.client.Fetch(uidSet, fetchOptions).Collect()
+	if err != nil {
+		return nil, yoyerrors.FromIMAPError(err)
+	}
+	if len(msgs) == 0 || msgs[0].BodyStructure == nil {
+		return nil, yoyerrors.New(fmt.Sprintf("message UID %d not found", uid), yoyerrors.ExitNotFound)
+	}
+
+	return attachmentsFromBodyStructure(msgs[0].BodyStructure), nil
+}
+
+// FetchAttachment writes the decoded content of an attachment to w. Only the
+// attachment's body section is fetched.
+func (ic *IMAPClient) FetchAttachment(folder string, uid uint32, att *Attachment, w io.Writer) error {
+	part, err := parsePartPath(att.Part)
+	if err != nil {
+		return yoyerrors.Wrap("invalid attachment part", err, yoyerrors.ExitInvalidInput)
+	}
+
+	if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
+		return yoyerrors.FromIMAPError(err)
+	}
+
+	uidSet := imap.UIDSetNum(imap.UID(uid))
+
+	fetchOptions := &imap.FetchOptions{
+		UID:         true,
+		BodySection: []*imap.FetchItemBodySection{{Part: part, Peek: true}},
+	}
+
+	fetchCmd := ic.client.Fetch(uidSet, fetchOptions)
+
+	msg := fetchCmd.Next()
+	if msg == nil {
+		if err := fetchCmd.Close(); err != nil {
+			return yoyerrors.FromIMAPError(err)
+		}
+		return yoyerrors.New(fmt.Sprintf("message UID %d not found", uid), yoyerrors.ExitNotFound)
+	}
+
+	found := false
+	for {
+		item := msg.Next()
+		if item == nil {
+			break
+		}
+		data, ok := item.(imapclient.FetchItemDataBodySection)
+		if !ok {
+			continue
+		}
+		found = true
+
+		var h message.Header
+		h.Set("Content-Transfer-Encoding", att.Encoding)
+		e, err := message.New(h, data.Literal)
+		if err != nil {
+			fetchCmd.Close()
+			return yoyerrors.Wrap("decoding attachment", err, yoyerrors.ExitGeneral)
+		}
+		if _, err := io.Copy(w, e.Body); err != nil {
+			fetchCmd.Close()
+			return fmt.Errorf("writing attachment: %w", err)
+		}
+	}
+
+	if err := fetchCmd.Close(); err != nil {
+		return yoyerrors.FromIMAPError(err)
+	}
+	if !found {
+		return yoyerrors.New(fmt.Sprintf("part %s of message UID %d not found", att.Part, uid), yoyerrors.ExitNotFound)
+	}
+
+	return nil
+}
+
+// attachmentsFromBodyStructure collects the attachment parts of a message.
+func attachmentsFromBodyStructure(bs imap.BodyStructure) []Attachment {
+	var attachments []Attachment
+	bs.Walk(func(path []int, part imap.BodyStructure) bool {
+		single, ok := part.(*imap.BodyStructureSinglePart)
+		if !ok || !isAttachmentPart(single) {
+			return true
+		}
+		attachments = append(attachments, Attachment{
+			Index:       len(attachments) + 1,
+			Part:        formatPartPath(path),
+			Filename:    single.Filename(),
+			ContentType: single.MediaType(),
+			Size:        int(single.Size),
+			Encoding:    strings.ToLower(single.Encoding),
+		})
+		return true
+	})
+	return attachments
+}
+
+// isAttachmentPart reports whether a body part is an attachment rather than
+// message text.
+func isAttachmentPart(part *imap.BodyStructureSinglePart) bool {
+	if disp := part.Disposition(); disp != nil && strings.EqualFold(disp.Value, "attachment") {
+		return true
+	}
+	if part.MediaType() == "message/rfc822" {
+		return true
+	}
+	// Inline parts with a filename (e.g. embedded images) are attachments
+	// too, unless they are the text of the message.
+	return part.Filename() != "" && !strings.EqualFold(part.Type, "text")
+}
+
+// formatPartPath formats an IMAP part path such as []int{2, 1} as "2.1".
+func formatPartPath(path []int) string {
+	parts := make([]string, len(path))
+	for i, n := range path {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// parsePartPath parses a part path such as "2.1".
+func parsePartPath(s string) ([]int, error) {
+	fields := strings.Split(s, ".")
+	path := make([]int, len(fields))
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid part path %q", s)
+		}
+		path[i] = n
+	}
+	return path, nil
+}
+
+// writeAttachmentFile adds the file at path to mw as a base64-encoded
+// attachment part.
+func writeAttachmentFile(mw *message.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening attachment: %w", err)
+	}
+	defer f.Close()
+
+	contentType, err := detectContentType(f, path)
+	if err != nil {
+		return fmt.Errorf("reading attachment %s: %w", path, err)
+	}
+
+	var h message.Header
+	setAttachmentHeader(&h, contentType, filepath.Base(path))
+
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("creating attachment part: %w", err)
+	}
+	if _, err := io.Copy(pw, f); err != nil {
+		pw.Close()
+		return fmt.Errorf("writing attachment %s: %w", path, err)
+	}
+	return pw.Close()
+}
+
+// setAttachmentHeader sets the content headers of an attachment part.
+//
+// mime.FormatMediaType encodes non-ASCII filenames as RFC 2231 extended
+// parameters, which is what mail clients expect for Content-Disposition.
+func setAttachmentHeader(h *message.Header, contentType, filename string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = filename
+	h.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	h.Set("Content-Transfer-Encoding", "base64")
+}
+
+// detectContentType determines the MIME type of an attachment from its file
+// extension, falling back to sniffing the first 512 bytes. The file offset is
+// reset to the start before returning.
+func detectContentType(f *os.File, path string) (string, error) {
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(path))); t != "" {
+		return t, nil
+	}
+
+	var buf [512]byte
+	n, err := io.ReadFull(f, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
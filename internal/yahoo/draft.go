@@ -0,0 +1,140 @@
+package yahoo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+)
+
+// ComposeDraft creates a draft from opts. Unlike a sent message, a draft
+// keeps its Bcc recipients in the header.
+func ComposeDraft(opts *SendOptions) ([]byte, error) {
+	draft := *opts
+	if len(opts.Bcc) > 0 {
+		draft.Headers = maps.Clone(opts.Headers)
+		if draft.Headers == nil {
+			draft.Headers = map[string]string{}
+		}
+		draft.Headers["Bcc"] = strings.Join(opts.Bcc, ", ")
+	}
+
+	raw, err := ComposeMessage(&draft)
+	opts.MessageID = draft.MessageID
+	return raw, err
+}
+
+// ParseDraft reads a draft back into options for sending or saving it again.
+// The first text/plain and text/html parts become the body; every other
+// part, such as an attachment, is kept in Parts. A draft written elsewhere
+// with only HTML gets a text body rendered from it.
+func ParseDraft(raw []byte) (*SendOptions, error) {
+	mr, err := mail.CreateReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("creating mail reader: %w", err)
+	}
+	defer mr.Close()
+
+	msg := &Message{}
+	parseHeader(mr.Header, msg)
+
+	opts := &SendOptions{
+		From:      msg.From.Address,
+		To:        addressStrings(msg.To),
+		Cc:        addressStrings(msg.Cc),
+		Subject:   msg.Subject,
+		ReplyTo:   msg.InReplyTo,
+		MessageID: msg.MessageID,
+	}
+	if addrs, err := mr.Header.AddressList("Bcc"); err == nil {
+		for _, a := range addrs {
+			opts.Bcc = append(opts.Bcc, a.Address)
+		}
+	}
+	if refs := mr.Header.Get("References"); refs != "" {
+		opts.Headers = map[string]string{"References": refs}
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !message.IsUnknownCharset(err) && !message.IsUnknownEncoding(err) {
+			return nil, fmt.Errorf("reading draft part: %w", err)
+		}
+
+		body, err := io.ReadAll(part.Body)
+		if err != nil {
+			return nil, fmt.Errorf("reading draft part: %w", err)
+		}
+
+		var h message.Header
+		switch ph := part.Header.(type) {
+		case *mail.InlineHeader:
+			ct, _, _ := ph.ContentType()
+			switch {
+			case ct == "text/plain" && opts.Body == "":
+				opts.Body = string(body)
+				continue
+			case ct == "text/html" && opts.HTMLBody == "":
+				opts.HTMLBody = string(body)
+				continue
+			}
+			h = ph.Header
+		case *mail.AttachmentHeader:
+			h = ph.Header
+		}
+		opts.Parts = append(opts.Parts, Part{Header: contentHeader(h), Body: body})
+	}
+
+	if opts.Body == "" && opts.HTMLBody != "" {
+		opts.Body = HTMLToText(opts.HTMLBody)
+	}
+	return opts, nil
+}
+
+// contentHeader returns the Content-* fields of h. Text parts are marked as
+// UTF-8, since reading them has already converted their charset.
+func contentHeader(h message.Header) message.Header {
+	var out message.Header
+	fields := h.Fields()
+	for fields.Next() {
+		if strings.HasPrefix(strings.ToLower(fields.Key()), "content-") {
+			out.Add(fields.Key(), fields.Value())
+		}
+	}
+
+	mediaType, params, _ := out.ContentType()
+	if strings.HasPrefix(mediaType, "text/") && params["charset"] != "" {
+		params["charset"] = "utf-8"
+		out.SetContentType(mediaType, params)
+	}
+	return out
+}
+
+// writePart adds a kept part to mw, re-encoded according to its header.
+func writePart(mw *message.Writer, p Part) error {
+	pw, err := mw.CreatePart(p.Header)
+	if err != nil {
+		return fmt.Errorf("creating part: %w", err)
+	}
+	if _, err := pw.Write(p.Body); err != nil {
+		pw.Close()
+		return fmt.Errorf("writing part: %w", err)
+	}
+	return pw.Close()
+}
+
+// addressStrings returns the bare addresses of addrs.
+func addressStrings(addrs []Address) []string {
+	var out []string
+	for _, a := range addrs {
+		out = append(out, a.Address)
+	}
+	return out
+}
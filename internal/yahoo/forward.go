@@ -0,0 +1,138 @@
+package yahoo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/emersion/go-message"
+)
+
+// writeForwardedMessage adds the original message to mw, either as a single
+// message/rfc822 attachment or by copying its MIME parts. A message with a
+// part that cannot be decoded is always attached, since copying the part
+// would mislabel its bytes.
+func writeForwardedMessage(mw *message.Writer, fwd *ForwardedMessage) error {
+	if fwd.AsAttachment || !decodable(fwd.Raw) {
+		return writeRFC822Part(mw, fwd.Raw)
+	}
+
+	e, err := message.Read(bytes.NewReader(fwd.Raw))
+	if err != nil {
+		return fmt.Errorf("reading forwarded message: %w", err)
+	}
+
+	// The parts of a multipart/mixed original become siblings of the new
+	// text part; anything else (a single part or multipart/alternative) is
+	// kept as one nested part so its structure is unchanged.
+	mediaType, _, _ := e.Header.ContentType()
+	if mediaType == "multipart/mixed" {
+		mr := e.MultipartReader()
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("reading forwarded part: %w", err)
+			}
+			if err := copyEntity(mw, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return copyEntity(mw, e)
+}
+
+// copyEntity writes e, including any nested parts, as a new part of mw. Only
+// the Content-* header fields of e are kept.
+func copyEntity(mw *message.Writer, e *message.Entity) error {
+	pw, err := mw.CreatePart(contentHeader(e.Header))
+	if err != nil {
+		return fmt.Errorf("creating forwarded part: %w", err)
+	}
+
+	if mr := e.MultipartReader(); mr != nil {
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pw.Close()
+				return fmt.Errorf("reading forwarded part: %w", err)
+			}
+			if err := copyEntity(pw, p); err != nil {
+				pw.Close()
+				return err
+			}
+		}
+	} else if _, err := io.Copy(pw, e.Body); err != nil {
+		pw.Close()
+		return fmt.Errorf("writing forwarded part: %w", err)
+	}
+
+	return pw.Close()
+}
+
+// decodable reports whether every part of raw has a transfer encoding and
+// charset that go-message can decode, so that its text can be re-encoded
+// as UTF-8.
+func decodable(raw []byte) bool {
+	e, err := message.Read(bytes.NewReader(raw))
+	if err != nil {
+		return false
+	}
+	return e.Walk(func(_ []int, _ *message.Entity, err error) error { return err }) == nil
+}
+
+// writeRFC822Part adds raw as a message/rfc822 attachment. The message is
+// copied byte for byte.
+func writeRFC822Part(mw *message.Writer, raw []byte) error {
+	filename := "forwarded.eml"
+	if hdr, err := ParseMessageHeader(bytes.NewReader(raw)); err == nil && hdr.Subject != "" {
+		filename = sanitizeFilename(hdr.Subject) + ".eml"
+	}
+
+	// message/rfc822 only allows 7bit, 8bit or binary encodings.
+	encoding := "7bit"
+	for _, b := range raw {
+		if b >= 0x80 {
+			encoding = "8bit"
+			break
+		}
+	}
+
+	var h message.Header
+	h.Set("Content-Type", "message/rfc822")
+	h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	h.Set("Content-Transfer-Encoding", encoding)
+
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("creating message/rfc822 part: %w", err)
+	}
+	if _, err := pw.Write(raw); err != nil {
+		pw.Close()
+		return fmt.Errorf("writing forwarded message: %w", err)
+	}
+	return pw.Close()
+}
+
+// sanitizeFilename replaces characters that are unsafe in filenames.
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	return name
+}
@@ -0,0 +1,31 @@
+package yahoo
+
+import "testing"
+
+func TestDecodable(t *testing.T) {
+	multipart := func(contentType, encoding string) string {
+		return "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+			"--b\r\nContent-Type: text/plain; charset=utf-8\r\n\r\nhello\r\n" +
+			"--b\r\nContent-Type: " + contentType + "\r\nContent-Transfer-Encoding: " + encoding + "\r\n\r\nx\r\n" +
+			"--b--\r\n"
+	}
+	tests := []struct {
+		name string
+		raw  string
+		want bool
+	}{
+		{"utf-8 text", "Content-Type: text/plain; charset=utf-8\r\n\r\nhello\r\n", true},
+		{"latin-1 text", "Content-Type: text/plain; charset=iso-8859-1\r\n\r\nh\xe9\r\n", true},
+		{"unknown charset", "Content-Type: text/plain; charset=x-made-up\r\n\r\nhello\r\n", false},
+		{"multipart", multipart("application/pdf", "base64"), true},
+		{"unknown charset in a part", multipart("text/plain; charset=x-made-up", "8bit"), false},
+		{"unknown encoding in a part", multipart("application/octet-stream", "x-uuencode"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodable([]byte(tt.raw)); got != tt.want {
+				t.Errorf("decodable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,721 @@
+package yahoo
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// HTMLToText renders an HTML document as readable plain text. Paragraphs,
+// headings, lists, block quotes and tables keep their structure, links are
+// turned into numbered footnotes, and scripts, styles and hidden elements are
+// dropped.
+func HTMLToText(src string) string {
+	return htmlToText(src, true)
+}
+
+// htmlToText renders HTML as text, with or without link footnotes.
+func htmlToText(src string, footnotes bool) string {
+	root := parseHTML(src)
+
+	r := &htmlRenderer{}
+	if footnotes {
+		r.links = &linkList{index: map[string]int{}}
+	}
+	r.renderChildren(root)
+	r.flushLine()
+
+	text := strings.TrimRight(r.out.String(), "\n")
+	if r.links != nil && len(r.links.urls) > 0 {
+		var b strings.Builder
+		b.WriteString(text)
+		b.WriteString("\n\n")
+		for i, u := range r.links.urls {
+			fmt.Fprintf(&b, "[%d] %s\n", i+1, u)
+		}
+		text = strings.TrimRight(b.String(), "\n")
+	}
+	return strings.TrimLeft(text, "\n")
+}
+
+// htmlNode is an element or text node of a parsed HTML document.
+type htmlNode struct {
+	tag      string // empty for text nodes
+	attrs    map[string]string
+	text     string
+	children []*htmlNode
+	parent   *htmlNode
+}
+
+// Elements that never have content.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// Elements whose content is raw text up to the matching end tag.
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "textarea": true, "title": true,
+}
+
+// Elements whose content is never rendered.
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "title": true,
+	"template": true, "noscript": true, "svg": true, "object": true,
+	"iframe": true, "select": true, "button": true, "form": true,
+}
+
+// Block elements that implicitly close an open <p>.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"center": true, "dd": true, "div": true, "dl": true, "dt": true,
+	"fieldset": true, "figure": true, "footer": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "li": true, "main": true, "nav": true, "ol": true, "p": true,
+	"pre": true, "section": true, "table": true, "ul": true,
+}
+
+// parseHTML builds a lenient document tree from src. It handles the
+// malformed markup common in mail (unclosed tags, stray end tags) by
+// implicitly closing elements the way browsers do for the common cases.
+func parseHTML(src string) *htmlNode {
+	root := &htmlNode{tag: "#root"}
+	cur := root
+
+	openElement := func(n *htmlNode) {
+		n.parent = cur
+		cur.children = append(cur.children, n)
+		if !voidElements[n.tag] {
+			cur = n
+		}
+	}
+	// closeElement pops up to and including the nearest open element named
+	// tag, stopping at any element in stop. It reports whether it closed one.
+	closeElement := func(tag string, stop ...string) bool {
+		for n := cur; n != root; n = n.parent {
+			if n.tag == tag {
+				cur = n.parent
+				return true
+			}
+			for _, s := range stop {
+				if n.tag == s {
+					return false
+				}
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < len(src); {
+		if src[i] != '<' {
+			j := strings.IndexByte(src[i:], '<')
+			if j < 0 {
+				j = len(src) - i
+			}
+			cur.children = append(cur.children, &htmlNode{text: html.UnescapeString(src[i : i+j]), parent: cur})
+			i += j
+			continue
+		}
+
+		rest := src[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return root
+			}
+			i += 4 + end + 3
+			continue
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			i += end + 1
+			continue
+		}
+
+		tag, attrs, closing, selfClosing, n := parseTag(rest)
+		if n == 0 {
+			// Not a tag, e.g. "a < b".
+			cur.children = append(cur.children, &htmlNode{text: "<", parent: cur})
+			i++
+			continue
+		}
+		i += n
+
+		if closing {
+			switch tag {
+			case "br":
+				openElement(&htmlNode{tag: "br"})
+			case "p":
+				if !closeElement("p", "div", "td", "th", "li", "blockquote") {
+					// A stray </p> produces an empty paragraph in browsers.
+					openElement(&htmlNode{tag: "p"})
+					closeElement("p")
+				}
+			default:
+				closeElement(tag)
+			}
+			continue
+		}
+
+		// Implicitly close elements that cannot contain the new one.
+		switch {
+		case tag == "li":
+			closeElement("li", "ul", "ol")
+		case tag == "dt" || tag == "dd":
+			if !closeElement("dd", "dl") {
+				closeElement("dt", "dl")
+			}
+		case tag == "tr":
+			closeElement("tr", "table")
+		case tag == "td" || tag == "th":
+			if !closeElement("td", "tr", "table") {
+				closeElement("th", "tr", "table")
+			}
+		}
+		if blockElements[tag] {
+			closeElement("p", "div", "td", "th", "li", "blockquote")
+		}
+
+		node := &htmlNode{tag: tag, attrs: attrs}
+		openElement(node)
+
+		if rawTextElements[tag] && !selfClosing {
+			end := indexFold(src[i:], "</"+tag)
+			if end < 0 {
+				end = len(src) - i
+			}
+			node.children = append(node.children, &htmlNode{text: src[i : i+end], parent: node})
+			i += end
+			if gt := strings.IndexByte(src[i:], '>'); gt >= 0 {
+				i += gt + 1
+			} else {
+				i = len(src)
+			}
+			cur = node.parent
+			continue
+		}
+		if selfClosing && !voidElements[tag] {
+			cur = node.parent
+		}
+	}
+
+	return root
+}
+
+// parseTag parses the start or end tag at the beginning of s. It returns the
+// number of bytes consumed, or 0 if s does not start with a tag.
+func parseTag(s string) (tag string, attrs map[string]string, closing, selfClosing bool, n int) {
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		closing = true
+		i++
+	}
+	start := i
+	for i < len(s) && (isASCIILetter(s[i]) || (i > start && (s[i] >= '0' && s[i] <= '9' || s[i] == '-' || s[i] == ':'))) {
+		i++
+	}
+	if i == start {
+		return "", nil, false, false, 0
+	}
+	tag = strings.ToLower(s[start:i])
+	attrs = map[string]string{}
+
+	for i < len(s) {
+		for i < len(s) && isHTMLSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			return tag, attrs, closing, selfClosing, i + 1
+		}
+		if s[i] == '/' {
+			selfClosing = true
+			i++
+			continue
+		}
+
+		nameStart := i
+		for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(s[nameStart:i])
+		for i < len(s) && isHTMLSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) || s[i] != '=' {
+			attrs[name] = ""
+			continue
+		}
+		i++
+		for i < len(s) && isHTMLSpace(s[i]) {
+			i++
+		}
+		var value string
+		if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+			q := s[i]
+			end := strings.IndexByte(s[i+1:], q)
+			if end < 0 {
+				return tag, attrs, closing, selfClosing, len(s)
+			}
+			value = s[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			valueStart := i
+			for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '>' {
+				i++
+			}
+			value = s[valueStart:i]
+		}
+		attrs[name] = html.UnescapeString(value)
+	}
+
+	return tag, attrs, closing, selfClosing, len(s)
+}
+
+func isASCIILetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func isHTMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+// indexFold is strings.Index with ASCII case folding, for an ASCII substr.
+// It compares the bytes of s directly, so the offset is valid in s even
+// when s holds invalid UTF-8 or characters whose lowercase form is longer.
+func indexFold(s, substr string) int {
+	n := len(substr)
+	for i := 0; i+n <= len(s); i++ {
+		j := 0
+		for j < n && lowerASCII(s[i+j]) == lowerASCII(substr[j]) {
+			j++
+		}
+		if j == n {
+			return i
+		}
+	}
+	return -1
+}
+
+func lowerASCII(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// linkList numbers link targets for the footnotes, shared between nested
+// renderers so table cells continue the numbering.
+type linkList struct {
+	urls  []string
+	index map[string]int
+}
+
+func (l *linkList) add(url string) int {
+	if n, ok := l.index[url]; ok {
+		return n
+	}
+	l.urls = append(l.urls, url)
+	l.index[url] = len(l.urls)
+	return len(l.urls)
+}
+
+// htmlRenderer writes an htmlNode tree as text. Inline content accumulates
+// in line and is flushed, with the current indentation prefix, whenever a
+// block boundary is reached.
+type htmlRenderer struct {
+	out    strings.Builder
+	line   strings.Builder
+	prefix []string
+	// marker replaces the innermost prefix on the next line, e.g. "- ".
+	marker string
+	space  bool
+	// blank is the number of empty lines to write before the next line,
+	// quoted up to prefix depth blankDepth.
+	blank      int
+	blankDepth int
+	pre        int
+	links      *linkList
+}
+
+func (r *htmlRenderer) renderChildren(n *htmlNode) {
+	for _, c := range n.children {
+		r.render(c)
+	}
+}
+
+func (r *htmlRenderer) render(n *htmlNode) {
+	if n.tag == "" {
+		r.text(n.text)
+		return
+	}
+	if skippedElements[n.tag] || isHidden(n) {
+		return
+	}
+
+	switch n.tag {
+	case "br":
+		r.lineBreak()
+	case "hr":
+		r.block(1)
+		r.line.WriteString(strings.Repeat("-", 40))
+		r.block(1)
+	case "p", "address", "figure", "center", "dl":
+		r.block(1)
+		r.renderChildren(n)
+		r.block(1)
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		r.block(1)
+		r.renderChildren(n)
+		heading := strings.TrimSpace(r.line.String())
+		r.block(0)
+		if (n.tag == "h1" || n.tag == "h2") && heading != "" {
+			underline := "="
+			if n.tag == "h2" {
+				underline = "-"
+			}
+			r.line.WriteString(strings.Repeat(underline, utf8.RuneCountInString(heading)))
+		}
+		r.block(1)
+	case "blockquote":
+		r.block(1)
+		r.prefix = append(r.prefix, "> ")
+		r.renderChildren(n)
+		r.flushLine()
+		r.prefix = r.prefix[:len(r.prefix)-1]
+		r.block(1)
+	case "pre":
+		r.block(1)
+		r.pre++
+		r.renderChildren(n)
+		r.pre--
+		r.block(1)
+	case "ul", "ol":
+		r.block(0)
+		r.renderList(n)
+		r.block(0)
+	case "dd":
+		r.block(0)
+		r.prefix = append(r.prefix, "    ")
+		r.renderChildren(n)
+		r.block(0)
+		r.prefix = r.prefix[:len(r.prefix)-1]
+	case "table":
+		r.block(0)
+		r.renderTable(n)
+		r.block(0)
+	case "a":
+		r.renderLink(n)
+	case "img":
+		if alt := strings.TrimSpace(n.attrs["alt"]); alt != "" {
+			r.text("[" + alt + "]")
+		}
+	default:
+		if blockElements[n.tag] || n.tag == "tr" || n.tag == "li" {
+			r.block(0)
+			r.renderChildren(n)
+			r.block(0)
+			return
+		}
+		r.renderChildren(n)
+	}
+}
+
+// renderList renders the <li> children of a <ul> or <ol>.
+func (r *htmlRenderer) renderList(n *htmlNode) {
+	num := 1
+	if start, err := parsePositiveInt(n.attrs["start"]); err == nil {
+		num = start
+	}
+	for _, c := range n.children {
+		if c.tag != "li" {
+			r.render(c)
+			continue
+		}
+		marker := "- "
+		if n.tag == "ol" {
+			marker = fmt.Sprintf("%d. ", num)
+			num++
+		}
+		r.block(0)
+		r.prefix = append(r.prefix, strings.Repeat(" ", len(marker)))
+		r.marker = marker
+		r.renderChildren(c)
+		r.block(0)
+		r.marker = ""
+		r.prefix = r.prefix[:len(r.prefix)-1]
+	}
+}
+
+// renderLink renders an anchor's text followed by a footnote reference.
+func (r *htmlRenderer) renderLink(n *htmlNode) {
+	outLen, start := r.out.Len(), r.line.Len()
+	r.renderChildren(n)
+	if r.links == nil {
+		return
+	}
+
+	href := strings.TrimSpace(n.attrs["href"])
+	lower := strings.ToLower(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(lower, "javascript:") {
+		return
+	}
+
+	// Links around block content have flushed lines; they still get a
+	// footnote.
+	text := "-"
+	if r.out.Len() == outLen {
+		text = strings.TrimSpace(r.line.String()[start:])
+	}
+	// Links without text (usually image-only banners and tracking pixels)
+	// are dropped, and so are links whose text already is the address.
+	if text == "" || text == href || text == strings.TrimPrefix(href, "mailto:") {
+		return
+	}
+	r.line.WriteString(fmt.Sprintf("[%d]", r.links.add(href)))
+}
+
+// renderTable renders data tables as aligned columns and layout tables (the
+// ones mail templates use for positioning) as a sequence of blocks.
+func (r *htmlRenderer) renderTable(n *htmlNode) {
+	var rows [][]*htmlNode
+	var collect func(n *htmlNode)
+	collect = func(n *htmlNode) {
+		for _, c := range n.children {
+			switch c.tag {
+			case "tr":
+				var cells []*htmlNode
+				for _, cell := range c.children {
+					if cell.tag == "td" || cell.tag == "th" {
+						if !isHidden(cell) {
+							cells = append(cells, cell)
+						}
+					}
+				}
+				rows = append(rows, cells)
+			case "thead", "tbody", "tfoot":
+				collect(c)
+			}
+		}
+	}
+	collect(n)
+
+	// Render every cell on its own so we can decide on the layout.
+	texts := make([][]string, len(rows))
+	tabular := false
+	for i, cells := range rows {
+		texts[i] = make([]string, len(cells))
+		for j, cell := range cells {
+			texts[i][j] = r.renderFragment(cell)
+		}
+		if len(cells) > 1 {
+			tabular = true
+		}
+	}
+	for i, cells := range texts {
+		if len(rows[i]) < 2 {
+			continue
+		}
+		for _, t := range cells {
+			if strings.Contains(t, "\n") || utf8.RuneCountInString(t) > 60 {
+				tabular = false
+			}
+		}
+	}
+
+	if !tabular {
+		for _, cells := range texts {
+			for _, t := range cells {
+				if t == "" {
+					continue
+				}
+				r.block(0)
+				r.writeLines(t)
+				r.block(0)
+			}
+		}
+		return
+	}
+
+	// Spacer columns that are empty in every row are dropped.
+	var widths []int
+	for _, cells := range texts {
+		for j, t := range cells {
+			if j >= len(widths) {
+				widths = append(widths, -1)
+			}
+			if t != "" {
+				widths[j] = max(widths[j], utf8.RuneCountInString(t))
+			}
+		}
+	}
+	for i, cells := range texts {
+		if len(rows[i]) < 2 {
+			if len(cells) == 1 && cells[0] != "" {
+				r.block(0)
+				r.writeLines(cells[0])
+				r.block(0)
+			}
+			continue
+		}
+		var cols []string
+		var pads []int
+		for j, t := range cells {
+			if widths[j] < 0 {
+				continue
+			}
+			cols = append(cols, t)
+			pads = append(pads, widths[j]-utf8.RuneCountInString(t))
+		}
+		var line strings.Builder
+		for j, t := range cols {
+			if j > 0 {
+				line.WriteString(" | ")
+			}
+			line.WriteString(t)
+			if j < len(cols)-1 {
+				line.WriteString(strings.Repeat(" ", pads[j]))
+			}
+		}
+		if strings.Trim(line.String(), " |") != "" {
+			r.block(0)
+			r.line.WriteString(strings.TrimRight(line.String(), " "))
+			r.block(0)
+		}
+	}
+}
+
+// renderFragment renders the children of n with a fresh renderer that shares
+// the link footnotes.
+func (r *htmlRenderer) renderFragment(n *htmlNode) string {
+	sub := &htmlRenderer{links: r.links, pre: r.pre}
+	sub.renderChildren(n)
+	sub.flushLine()
+	return strings.Trim(sub.out.String(), "\n")
+}
+
+// writeLines writes pre-rendered text, one output line per input line.
+func (r *htmlRenderer) writeLines(text string) {
+	for i, l := range strings.Split(text, "\n") {
+		if i > 0 {
+			r.lineBreak()
+		}
+		r.line.WriteString(l)
+	}
+}
+
+// text adds inline text, collapsing whitespace unless inside <pre>.
+func (r *htmlRenderer) text(s string) {
+	if r.pre > 0 {
+		for i, l := range strings.Split(s, "\n") {
+			if i > 0 {
+				r.lineBreak()
+			}
+			r.line.WriteString(strings.TrimRight(l, "\r"))
+		}
+		return
+	}
+	for _, c := range s {
+		switch {
+		case c == '\u200b' || c == '\u200c' || c == '\u200d' || c == '\ufeff':
+			// Zero-width characters pad preheaders in mail templates.
+			continue
+		case unicode.IsSpace(c):
+			r.space = r.line.Len() > 0
+			continue
+		}
+		if r.space {
+			r.line.WriteByte(' ')
+			r.space = false
+		}
+		r.line.WriteRune(c)
+	}
+}
+
+// lineBreak ends the current line, emitting an empty line if it is empty.
+func (r *htmlRenderer) lineBreak() {
+	r.writeLine(strings.TrimRight(r.line.String(), " "))
+	r.line.Reset()
+	r.space = false
+}
+
+// flushLine ends the current line if it has content.
+func (r *htmlRenderer) flushLine() {
+	if strings.TrimSpace(r.line.String()) != "" {
+		r.writeLine(strings.TrimRight(r.line.String(), " "))
+	}
+	r.line.Reset()
+	r.space = false
+}
+
+// block ends the current line and requests at least blank empty lines
+// before the next line. Consecutive requests don't add up, and blank lines
+// are only written once more content follows.
+func (r *htmlRenderer) block(blank int) {
+	r.flushLine()
+	if r.out.Len() == 0 || blank == 0 {
+		return
+	}
+	// Blank lines between blocks belong to the outermost of the blocks, so
+	// leaving or entering a quote doesn't quote the separator.
+	if r.blank == 0 || len(r.prefix) < r.blankDepth {
+		r.blankDepth = len(r.prefix)
+	}
+	r.blank = max(r.blank, blank)
+}
+
+func (r *htmlRenderer) writeLine(s string) {
+	for ; r.blank > 0; r.blank-- {
+		quote := strings.Join(r.prefix[:min(r.blankDepth, len(r.prefix))], "")
+		r.out.WriteString(strings.TrimRight(quote, " "))
+		r.out.WriteByte('\n')
+	}
+	prefix := r.currentPrefix()
+	if s == "" {
+		prefix = strings.TrimRight(prefix, " ")
+	}
+	r.out.WriteString(prefix)
+	r.out.WriteString(s)
+	r.out.WriteByte('\n')
+}
+
+// currentPrefix returns the indentation for the next line. A pending list
+// marker replaces the innermost indentation level.
+func (r *htmlRenderer) currentPrefix() string {
+	var b strings.Builder
+	for i, p := range r.prefix {
+		if r.marker != "" && i == len(r.prefix)-1 {
+			b.WriteString(r.marker)
+			r.marker = ""
+			continue
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// isHidden reports whether an element is hidden with inline CSS, as used
+// for preheader text in newsletters.
+func isHidden(n *htmlNode) bool {
+	if _, ok := n.attrs["hidden"]; ok {
+		return true
+	}
+	style := strings.ToLower(strings.ReplaceAll(n.attrs["style"], " ", ""))
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+func parsePositiveInt(s string) (int, error) {
+	var n int
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%d", &n); err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
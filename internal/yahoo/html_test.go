@@ -0,0 +1,23 @@
+package yahoo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLToTextRawTextWithNonASCII(t *testing.T) {
+	junk := []string{
+		strings.Repeat("\xe9", 30), // Latin-1 bytes in a UTF-8 document
+		strings.Repeat("\xe9", 8),
+		strings.Repeat("Ⱥ", 20), // lowercases to a longer encoding
+		"caf\xe9 Ⱥ ünïcödé",
+	}
+	for _, tag := range []string{"style", "script", "title"} {
+		for _, j := range junk {
+			src := "<" + tag + ">" + j + "</" + strings.ToUpper(tag) + "><p>hello</p>"
+			if got := HTMLToText(src); got != "hello" {
+				t.Errorf("HTMLToText(%q) = %q, want %q", src, got, "hello")
+			}
+		}
+	}
+}
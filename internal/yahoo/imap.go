@@ -1,11 +1,14 @@
 package yahoo
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/emersion/go-imap/v2"
 	"github.com/emersion/go-imap/v2/imapclient"
@@ -53,7 +56,8 @@ func (ic *IMAPClient) Close() error {
 	return nil
 }
 
-// ListFolders returns all mail folders.
+// ListFolders returns all mail folders, including ones that only hold other
+// folders. See MailboxNames for the folders that can be searched.
 func (ic *IMAPClient) ListFolders() ([]Folder, error) {
 	listCmd := ic.client.List("", "*", nil)
 	var folders []Folder
@@ -96,6 +100,69 @@ func (ic *IMAPClient) ListFolders() ([]Folder, error) {
 	return folders, nil
 }
 
+// MailboxNames returns the names of all selectable mailboxes, sorted by name.
+func (ic *IMAPClient) MailboxNames() ([]string, error) {
+	listCmd := ic.client.List("", "*", nil)
+	var names []string
+
+	for {
+		mbox := listCmd.Next()
+		if mbox == nil {
+			break
+		}
+		if containsMailboxAttr(mbox.Attrs, imap.MailboxAttrNoSelect) ||
+			containsMailboxAttr(mbox.Attrs, imap.MailboxAttrNonExistent) {
+			continue
+		}
+		names = append(names, mbox.Mailbox)
+	}
+
+	if err := listCmd.Close(); err != nil {
+		return nil, yoyerrors.FromIMAPError(err)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// specialUseNames are the folder names tried when the server does not mark a
+// mailbox with the special-use attribute.
+var specialUseNames = map[imap.MailboxAttr][]string{
+	imap.MailboxAttrTrash:  {"Trash", "Deleted Items", "Deleted Messages"},
+	imap.MailboxAttrSent:   {"Sent", "Sent Items", "Sent Messages"},
+	imap.MailboxAttrDrafts: {"Draft", "Drafts"},
+}
+
+// SpecialFolder returns the name of the mailbox with a special-use attribute
+// (RFC 6154) such as imap.MailboxAttrTrash.
+func (ic *IMAPClient) SpecialFolder(attr imap.MailboxAttr) (string, error) {
+	var options *imap.ListOptions
+	if ic.client.Caps().Has(imap.CapSpecialUse) {
+		options = &imap.ListOptions{ReturnSpecialUse: true}
+	}
+
+	mailboxes, err := ic.client.List("", "*", options).Collect()
+	if err != nil {
+		return "", yoyerrors.FromIMAPError(err)
+	}
+
+	for _, mbox := range mailboxes {
+		if containsMailboxAttr(mbox.Attrs, attr) {
+			return mbox.Mailbox, nil
+		}
+	}
+	for _, name := range specialUseNames[attr] {
+		for _, mbox := range mailboxes {
+			if strings.EqualFold(mbox.Mailbox, name) {
+				return mbox.Mailbox, nil
+			}
+		}
+	}
+
+	return "", yoyerrors.New(fmt.Sprintf("no %s folder found", strings.TrimPrefix(string(attr), "\\")), yoyerrors.ExitNotFound).
+		WithHint("Use 'yoy folders list' to see available folders.")
+}
+
 // CreateFolder creates a new mail folder.
 func (ic *IMAPClient) CreateFolder(name string) error {
 	if err := ic.client.Create(name, nil).Wait(); err != nil {
@@ -112,93 +179,246 @@ func (ic *IMAPClient) DeleteFolder(name string) error {
 	return nil
 }
 
-// ListMessages lists messages in a folder.
-func (ic *IMAPClient) ListMessages(folder string, limit uint32) ([]Message, error) {
+// ListMessages lists one page of messages in a folder, newest first.
+func (ic *IMAPClient) ListMessages(folder string, opts *ListOptions) (*MessagePage, error) {
 	mbox, err := ic.client.Select(folder, nil).Wait()
 	if err != nil {
 		return nil, yoyerrors.FromIMAPError(err)
 	}
 
 	if mbox.NumMessages == 0 {
-		return nil, nil
+		return &MessagePage{}, nil
+	}
+
+	var page *MessagePage
+	if opts.BeforeUID == 0 && opts.AfterUID == 0 && !opts.filtered() && opts.Sort.Key == "" {
+		page, err = ic.listBySeqNum(mbox.NumMessages, opts)
+	} else {
+		page, err = ic.listBySearch(opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Preview {
+		if err := ic.addPreviews(page.Messages); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// filtered reports whether any filter is set.
+func (opts *ListOptions) filtered() bool {
+	return opts.Unread || opts.Flagged || opts.Answered || opts.HasAttachment ||
+		opts.From != "" || !opts.Since.IsZero() || !opts.Until.IsZero()
+}
+
+// searchCriteria converts the filters to IMAP search criteria. SINCE and
+// BEFORE only compare dates in the server's time zone, so the date range is
+// widened by a day and narrowed again by filterByInternalDate.
+func (opts *ListOptions) searchCriteria() *imap.SearchCriteria {
+	criteria := &imap.SearchCriteria{}
+	if opts.Unread {
+		criteria.NotFlag = append(criteria.NotFlag, imap.FlagSeen)
+	}
+	if opts.Flagged {
+		criteria.Flag = append(criteria.Flag, imap.FlagFlagged)
 	}
+	if opts.Answered {
+		criteria.Flag = append(criteria.Flag, imap.FlagAnswered)
+	}
+	if opts.HasAttachment {
+		criteria.And(hasAttachmentCriteria())
+	}
+	if opts.From != "" {
+		criteria.Header = append(criteria.Header, imap.SearchCriteriaHeaderField{Key: "From", Value: opts.From})
+	}
+	if !opts.Since.IsZero() {
+		criteria.Since = opts.Since.AddDate(0, 0, -1)
+	}
+	if !opts.Until.IsZero() {
+		criteria.Before = opts.Until.AddDate(0, 0, 1)
+	}
+	if opts.BeforeUID > 0 || opts.AfterUID > 0 {
+		var uidSet imap.UIDSet
+		if opts.AfterUID > 0 {
+			uidSet.AddRange(imap.UID(opts.AfterUID+1), 0)
+		} else {
+			uidSet.AddRange(1, imap.UID(opts.BeforeUID-1))
+		}
+		criteria.UID = []imap.UIDSet{uidSet}
+	}
+	return criteria
+}
 
-	// Calculate range: fetch the last N messages.
-	start := uint32(1)
-	if mbox.NumMessages > limit {
-		start = mbox.NumMessages - limit + 1
+// listBySeqNum pages from the end of the mailbox using sequence numbers,
+// which needs no search. The cursor it returns is still a UID.
+func (ic *IMAPClient) listBySeqNum(numMessages uint32, opts *ListOptions) (*MessagePage, error) {
+	start, end, ok := opts.seqRange(numMessages)
+	if !ok {
+		return &MessagePage{}, nil
 	}
 
 	var seqSet imap.SeqSet
-	seqSet.AddRange(start, mbox.NumMessages)
+	seqSet.AddRange(start, end)
 
-	fetchOptions := &imap.FetchOptions{
-		UID:      true,
-		Flags:    true,
-		Envelope: true,
+	messages, err := ic.fetchMessages(seqSet)
+	if err != nil {
+		return nil, err
 	}
+	sortByUIDDesc(messages)
 
-	fetchCmd := ic.client.Fetch(seqSet, fetchOptions)
-	var messages []Message
+	page := &MessagePage{Messages: messages}
+	if start > 1 && len(messages) > 0 {
+		page.NextCursor = messages[len(messages)-1].UID
+	}
+	return page, nil
+}
 
-	for {
-		msg := fetchCmd.Next()
-		if msg == nil {
-			break
-		}
+// seqRange returns the sequence numbers of an unfiltered page: the newest
+// Offset messages are skipped, then Limit are taken. ok is false when the
+// page is empty.
+func (opts *ListOptions) seqRange(numMessages uint32) (start, end uint32, ok bool) {
+	if opts.Offset >= numMessages {
+		return 0, 0, false
+	}
+	end = numMessages - opts.Offset
+	start = 1
+	if opts.Limit > 0 && end > opts.Limit {
+		start = end - opts.Limit + 1
+	}
+	return start, end, true
+}
 
-		m := messageFromFetchData(msg)
-		messages = append(messages, m)
+// listBySearch pages through the messages matching the filters, relative to
+// a UID cursor if one is set.
+func (ic *IMAPClient) listBySearch(opts *ListOptions) (*MessagePage, error) {
+	uids, more, err := ic.searchUIDs(opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(uids) == 0 {
+		return &MessagePage{}, nil
 	}
 
-	if err := fetchCmd.Close(); err != nil {
-		return nil, yoyerrors.FromIMAPError(err)
+	messages, err := ic.fetchMessages(imap.UIDSetNum(uids...))
+	if err != nil {
+		return nil, err
+	}
+	if opts.Sort.Key == "" {
+		sortByUIDDesc(messages)
+	} else {
+		messages = orderByUIDs(messages, uids)
 	}
 
-	// Reverse to show newest first.
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+	page := &MessagePage{Messages: messages}
+	if more && opts.Sort.Key == "" {
+		page.NextCursor = uint32(uids[len(uids)-1])
 	}
+	return page, nil
+}
 
-	return messages, nil
+// searchUIDs returns the UIDs of one page of messages matching the filters,
+// and whether more follow. With BeforeUID (or no cursor) these are the newest
+// matches below the cursor, newest first; with AfterUID the oldest matches
+// above it, oldest first, so that walking forward never skips a message. A
+// sort order overrides either.
+func (ic *IMAPClient) searchUIDs(opts *ListOptions) ([]imap.UID, bool, error) {
+	if opts.AfterUID == 0 && opts.BeforeUID == 1 {
+		return nil, false, nil
+	}
+
+	found, err := ic.sortedSearch(opts.searchCriteria(), opts.Sort)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// "N:*" matches the last message even when its UID is below N, so the
+	// cursor bounds are checked again here.
+	var uids []imap.UID
+	for _, uid := range found {
+		if (opts.AfterUID == 0 || uint32(uid) > opts.AfterUID) &&
+			(opts.BeforeUID == 0 || uint32(uid) < opts.BeforeUID) {
+			uids = append(uids, uid)
+		}
+	}
+	if !opts.Since.IsZero() || !opts.Until.IsZero() {
+		if uids, err = ic.filterByInternalDate(uids, opts.Since, opts.Until); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if opts.Sort.Key == "" {
+		sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+		if opts.AfterUID == 0 {
+			// Newest first when walking backwards.
+			slices.Reverse(uids)
+		}
+	}
+
+	if opts.Offset >= uint32(len(uids)) {
+		return nil, false, nil
+	}
+	uids = uids[opts.Offset:]
+
+	more := false
+	if opts.Limit > 0 && uint32(len(uids)) > opts.Limit {
+		uids, more = uids[:opts.Limit], true
+	}
+	return uids, more, nil
 }
 
-// SearchMessages searches for messages matching a query in a folder.
-func (ic *IMAPClient) SearchMessages(folder, query string) ([]Message, error) {
-	if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
-		return nil, yoyerrors.FromIMAPError(err)
+// filterByInternalDate keeps the messages received in [since, until). A zero
+// bound is ignored.
+func (ic *IMAPClient) filterByInternalDate(uids []imap.UID, since, until time.Time) ([]imap.UID, error) {
+	if len(uids) == 0 {
+		return nil, nil
 	}
 
-	// Search in subject and from headers using OR.
-	criteria := &imap.SearchCriteria{
-		Or: [][2]imap.SearchCriteria{
-			{
-				imap.SearchCriteria{Header: []imap.SearchCriteriaHeaderField{{Key: "Subject", Value: query}}},
-				imap.SearchCriteria{Header: []imap.SearchCriteriaHeaderField{{Key: "From", Value: query}}},
-			},
-		},
+	fetchOptions := &imap.FetchOptions{
+		UID:          true,
+		InternalDate: true,
 	}
 
-	searchData, err := ic.client.Search(criteria, nil).Wait()
+	msgs, err := ic.client.Fetch(imap.UIDSetNum(uids...), fetchOptions).Collect()
 	if err != nil {
 		return nil, yoyerrors.FromIMAPError(err)
 	}
 
-	seqNums := searchData.AllSeqNums()
-	if len(seqNums) == 0 {
-		return nil, nil
+	inRange := make(map[imap.UID]bool, len(msgs))
+	for _, msg := range msgs {
+		if !since.IsZero() && msg.InternalDate.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !msg.InternalDate.Before(until) {
+			continue
+		}
+		inRange[msg.UID] = true
 	}
 
-	var seqSet imap.SeqSet
-	seqSet.AddNum(seqNums...)
+	// Keep the order of uids, which may be sorted.
+	var kept []imap.UID
+	for _, uid := range uids {
+		if inRange[uid] {
+			kept = append(kept, uid)
+		}
+	}
+	return kept, nil
+}
 
+// fetchMessages fetches the summary of a set of messages: UID, flags,
+// envelope, size and body structure, but not the content.
+func (ic *IMAPClient) fetchMessages(numSet imap.NumSet) ([]Message, error) {
 	fetchOptions := &imap.FetchOptions{
-		UID:      true,
-		Flags:    true,
-		Envelope: true,
+		UID:           true,
+		Flags:         true,
+		Envelope:      true,
+		RFC822Size:    true,
+		BodyStructure: &imap.FetchItemBodyStructure{Extended: true},
 	}
 
-	fetchCmd := ic.client.Fetch(seqSet, fetchOptions)
+	fetchCmd := ic.client.Fetch(numSet, fetchOptions)
 	var messages []Message
 
 	for {
@@ -206,6 +426,7 @@ func (ic *IMAPClient) SearchMessages(folder, query string) ([]Message, error) {
 		if msg == nil {
 			break
 		}
+
 		m := messageFromFetchData(msg)
 		messages = append(messages, m)
 	}
@@ -214,14 +435,121 @@ func (ic *IMAPClient) SearchMessages(folder, query string) ([]Message, error) {
 		return nil, yoyerrors.FromIMAPError(err)
 	}
 
-	// Reverse to show newest first.
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+	return messages, nil
+}
+
+// sortByUIDDesc orders messages newest first.
+func sortByUIDDesc(messages []Message) {
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].UID > messages[j].UID
+	})
+}
+
+// SearchMessages searches for messages matching a query in a folder. See
+// ParseQuery for the query syntax.
+func (ic *IMAPClient) SearchMessages(folder, query string, order SortOrder) ([]Message, error) {
+	return collectMessages(ic.StreamSearch(folder, query, order))
+}
+
+// SearchFolders runs the same search in several folders and merges the
+// results, newest first unless another order is given. Each message records
+// the folder it was found in.
+func (ic *IMAPClient) SearchFolders(folders []string, query string, order SortOrder) ([]Message, error) {
+	criteria, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Key != "" {
+		return ic.searchFoldersSorted(folders, criteria, order)
 	}
 
+	var messages []Message
+	for _, folder := range folders {
+		found, err := collectMessages(ic.streamMatches(folder, criteria, SortOrder{}))
+		if err != nil {
+			return nil, folderError(folder, err)
+		}
+		for i := range found {
+			found[i].Folder = folder
+		}
+		messages = append(messages, found...)
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Date.After(messages[j].Date)
+	})
+
 	return messages, nil
 }
 
+// searchFoldersSorted sorts the matches of all folders together, which the
+// SORT extension cannot do, so the sort values are fetched and compared here.
+func (ic *IMAPClient) searchFoldersSorted(folders []string, criteria *imap.SearchCriteria, order SortOrder) ([]Message, error) {
+	var entries []sortEntry
+	for _, folder := range folders {
+		if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
+			return nil, folderError(folder, yoyerrors.FromIMAPError(err))
+		}
+		resolved, err := ic.resolveCriteria(criteria)
+		if err != nil {
+			return nil, folderError(folder, err)
+		}
+		searchData, err := ic.client.UIDSearch(resolved, nil).Wait()
+		if err != nil {
+			return nil, folderError(folder, yoyerrors.FromIMAPError(err))
+		}
+		found, err := ic.fetchSortEntries(folder, searchData.AllUIDs())
+		if err != nil {
+			return nil, folderError(folder, err)
+		}
+		entries = append(entries, found...)
+	}
+	sortEntries(entries, order)
+
+	byFolder := map[string][]imap.UID{}
+	for _, e := range entries {
+		byFolder[e.folder] = append(byFolder[e.folder], e.uid)
+	}
+
+	type key struct {
+		folder string
+		uid    uint32
+	}
+	fetched := map[key]Message{}
+	for _, folder := range folders {
+		uids := byFolder[folder]
+		if len(uids) == 0 {
+			continue
+		}
+		if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
+			return nil, folderError(folder, yoyerrors.FromIMAPError(err))
+		}
+		messages, err := ic.fetchMessages(imap.UIDSetNum(uids...))
+		if err != nil {
+			return nil, folderError(folder, err)
+		}
+		for _, m := range messages {
+			m.Folder = folder
+			fetched[key{folder, m.UID}] = m
+		}
+	}
+
+	messages := make([]Message, 0, len(entries))
+	for _, e := range entries {
+		if m, ok := fetched[key{e.folder, uint32(e.uid)}]; ok {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+// folderError adds the folder name to an error from a multi-folder search.
+func folderError(folder string, err error) error {
+	return yoyerrors.Wrap(fmt.Sprintf("searching %s", folder), err, yoyerrors.ExitCodeFrom(err)).
+		WithHint(yoyerrors.HintFrom(err))
+}
+
 // ReadMessage fetches a complete message by UID.
 func (ic *IMAPClient) ReadMessage(folder string, uid uint32) (*Message, error) {
 	if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
@@ -234,6 +562,7 @@ func (ic *IMAPClient) ReadMessage(folder string, uid uint32) (*Message, error) {
 		UID:         true,
 		Flags:       true,
 		Envelope:    true,
+		RFC822Size:  true,
 		BodySection: []*imap.FetchItemBodySection{{}},
 	}
 
@@ -256,25 +585,157 @@ func (ic *IMAPClient) ReadMessage(folder string, uid uint32) (*Message, error) {
 	return &m, nil
 }
 
-// DeleteMessage marks a message as deleted and expunges.
-func (ic *IMAPClient) DeleteMessage(folder string, uid uint32) error {
+// FetchRawMessage fetches the complete source of a message by UID without
+// marking it as read.
+func (ic *IMAPClient) FetchRawMessage(folder string, uid uint32) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := ic.WriteRawMessage(folder, uid, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteRawMessage streams the exact source of a message to w without marking
+// it as read. The bytes are copied as the server sends them.
+func (ic *IMAPClient) WriteRawMessage(folder string, uid uint32, w io.Writer) error {
 	if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
 		return yoyerrors.FromIMAPError(err)
 	}
 
 	uidSet := imap.UIDSetNum(imap.UID(uid))
 
-	storeCmd := ic.client.Store(uidSet, &imap.StoreFlags{
-		Op:    imap.StoreFlagsAdd,
-		Flags: []imap.Flag{imap.FlagDeleted},
+	fetchOptions := &imap.FetchOptions{
+		UID:         true,
+		BodySection: []*imap.FetchItemBodySection{{Peek: true}},
+	}
+
+	fetchCmd := ic.client.Fetch(uidSet, fetchOptions)
+
+	msg := fetchCmd.Next()
+	if msg == nil {
+		if err := fetchCmd.Close(); err != nil {
+			return yoyerrors.FromIMAPError(err)
+		}
+		return yoyerrors.New(fmt.Sprintf("message UID %d not found", uid), yoyerrors.ExitNotFound)
+	}
+
+	found := false
+	for {
+		item := msg.Next()
+		if item == nil {
+			break
+		}
+		data, ok := item.(imapclient.FetchItemDataBodySection)
+		if !ok || data.Literal == nil {
+			continue
+		}
+		found = true
+		if _, err := io.Copy(w, data.Literal); err != nil {
+			fetchCmd.Close()
+			return fmt.Errorf("writing message source: %w", err)
+		}
+	}
+
+	if err := fetchCmd.Close(); err != nil {
+		return yoyerrors.FromIMAPError(err)
+	}
+	if !found {
+		return yoyerrors.New(fmt.Sprintf("message UID %d has no body", uid), yoyerrors.ExitIMAPError)
+	}
+
+	return nil
+}
+
+// FindUIDs returns the messages in a folder that are in uids and match
+// query, along with their count. A nil uids or empty query is not used as a
+// filter. Ranges such as 200:250 only yield UIDs that exist.
+func (ic *IMAPClient) FindUIDs(folder string, uids imap.UIDSet, query string) (imap.UIDSet, int, error) {
+	criteria := &imap.SearchCriteria{}
+	if query != "" {
+		parsed, err := ParseQuery(query)
+		if err != nil {
+			return nil, 0, err
+		}
+		criteria = parsed
+	}
+	if uids != nil {
+		criteria.UID = append(criteria.UID, uids)
+	}
+
+	if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
+		return nil, 0, yoyerrors.FromIMAPError(err)
+	}
+
+	criteria, err := ic.resolveCriteria(criteria)
+	if err != nil {
+		return nil, 0, err
+	}
+	searchData, err := ic.client.UIDSearch(criteria, nil).Wait()
+	if err != nil {
+		return nil, 0, yoyerrors.FromIMAPError(err)
+	}
+
+	found := searchData.AllUIDs()
+	if len(found) == 0 {
+		return nil, 0, nil
+	}
+	return imap.UIDSetNum(found...), len(found), nil
+}
+
+// DeleteMessages moves messages to the Trash folder and returns the name of
+// that folder. Messages already in Trash must be deleted permanently.
+func (ic *IMAPClient) DeleteMessages(folder string, uids imap.UIDSet) (string, error) {
+	trash, err := ic.SpecialFolder(imap.MailboxAttrTrash)
+	if err != nil {
+		return "", err
+	}
+	if trash == folder {
+		return "", yoyerrors.New(fmt.Sprintf("messages are already in %s", trash), yoyerrors.ExitInvalidInput).
+			WithHint("Use --permanent to delete them for good.")
+	}
+
+	if err := ic.MoveMessages(folder, uids, trash); err != nil {
+		return "", err
+	}
+	return trash, nil
+}
+
+// DeleteMessagesPermanently flags messages as deleted and expunges them with
+// UID EXPUNGE, so messages flagged \Deleted by other clients are left alone.
+// Without UIDPLUS, a plain EXPUNGE is used only when no other message in
+// the folder is flagged \Deleted.
+func (ic *IMAPClient) DeleteMessagesPermanently(folder string, uids imap.UIDSet) error {
+	if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
+		return yoyerrors.FromIMAPError(err)
+	}
+
+	uidPlus := ic.client.Caps().Has(imap.CapUIDPlus)
+	if !uidPlus {
+		others, err := ic.client.UIDSearch(&imap.SearchCriteria{
+			Flag: []imap.Flag{imap.FlagDeleted},
+			Not:  []imap.SearchCriteria{{UID: []imap.UIDSet{uids}}},
+		}, nil).Wait()
+		if err != nil {
+			return yoyerrors.FromIMAPError(err)
+		}
+		if len(others.AllUIDs()) > 0 {
+			return yoyerrors.New("server does not support UIDPLUS", yoyerrors.ExitIMAPError).
+				WithHint("Other messages in " + folder + " are flagged as deleted and a plain EXPUNGE would remove them too, so yoy refuses to run it.")
+		}
+	}
+
+	storeCmd := ic.client.Store(uids, &imap.StoreFlags{
+		Op:     imap.StoreFlagsAdd,
+		Silent: true,
+		Flags:  []imap.Flag{imap.FlagDeleted},
 	}, nil)
 	if err := storeCmd.Close(); err != nil {
 		return yoyerrors.FromIMAPError(err)
 	}
 
 	expungeCmd := ic.client.Expunge()
-	for expungeCmd.Next() != 0 {
-		// Drain expunge notifications.
+	if uidPlus {
+		expungeCmd = ic.client.UIDExpunge(uids)
 	}
 	if err := expungeCmd.Close(); err != nil {
 		return yoyerrors.FromIMAPError(err)
@@ -283,59 +744,88 @@ func (ic *IMAPClient) DeleteMessage(folder string, uid uint32) error {
 	return nil
 }
 
-// MoveMessage moves a message to a different folder.
-func (ic *IMAPClient) MoveMessage(folder string, uid uint32, destFolder string) error {
+// MoveMessages moves messages to a different folder.
+func (ic *IMAPClient) MoveMessages(folder string, uids imap.UIDSet, destFolder string) error {
+	// Without MOVE, go-imap falls back to COPY and EXPUNGE, which is only
+	// scoped to the moved messages when UID EXPUNGE is available.
+	caps := ic.client.Caps()
+	if !caps.Has(imap.CapMove) && !caps.Has(imap.CapUIDPlus) {
+		return yoyerrors.New("server supports neither MOVE nor UIDPLUS", yoyerrors.ExitIMAPError).
+			WithHint("Moving would expunge other messages flagged as deleted, so yoy refuses to do it.")
+	}
+
 	if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
 		return yoyerrors.FromIMAPError(err)
 	}
 
-	uidSet := imap.UIDSetNum(imap.UID(uid))
-
-	if _, err := ic.client.Move(uidSet, destFolder).Wait(); err != nil {
+	if _, err := ic.client.Move(uids, destFolder).Wait(); err != nil {
 		return yoyerrors.FromIMAPError(err)
 	}
 
 	return nil
 }
 
-// SetFlags sets flags on a message.
-func (ic *IMAPClient) SetFlags(folder string, uid uint32, flags []imap.Flag, add bool) error {
+// AppendMessage stores a raw message in a folder with the given flags and
+// returns its UID, or 0 if the server does not report it (no UIDPLUS).
+func (ic *IMAPClient) AppendMessage(folder string, raw []byte, flags []imap.Flag) (uint32, error) {
+	appendCmd := ic.client.Append(folder, int64(len(raw)), &imap.AppendOptions{
+		Flags: flags,
+		Time:  time.Now(),
+	})
+	if _, err := appendCmd.Write(raw); err != nil {
+		appendCmd.Close()
+		return 0, yoyerrors.FromIMAPError(err)
+	}
+	if err := appendCmd.Close(); err != nil {
+		return 0, yoyerrors.FromIMAPError(err)
+	}
+	data, err := appendCmd.Wait()
+	if err != nil {
+		return 0, yoyerrors.FromIMAPError(err)
+	}
+	return uint32(data.UID), nil
+}
+
+// SetFlags adds or removes flags on messages.
+func (ic *IMAPClient) SetFlags(folder string, uids imap.UIDSet, flags []imap.Flag, add bool) error {
 	if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
 		return yoyerrors.FromIMAPError(err)
 	}
 
-	uidSet := imap.UIDSetNum(imap.UID(uid))
-
 	op := imap.StoreFlagsAdd
 	if !add {
 		op = imap.StoreFlagsDel
 	}
 
-	storeCmd := ic.client.Store(uidSet, &imap.StoreFlags{
-		Op:    op,
-		Flags: flags,
+	storeCmd := ic.client.Store(uids, &imap.StoreFlags{
+		Op:     op,
+		Silent: true,
+		Flags:  flags,
 	}, nil)
-	return storeCmd.Close()
+	if err := storeCmd.Close(); err != nil {
+		return yoyerrors.FromIMAPError(err)
+	}
+	return nil
 }
 
-// StarMessage adds the \Flagged flag to a message.
-func (ic *IMAPClient) StarMessage(folder string, uid uint32) error {
-	return ic.SetFlags(folder, uid, []imap.Flag{imap.FlagFlagged}, true)
+// StarMessages adds the \Flagged flag to messages.
+func (ic *IMAPClient) StarMessages(folder string, uids imap.UIDSet) error {
+	return ic.SetFlags(folder, uids, []imap.Flag{imap.FlagFlagged}, true)
 }
 
-// UnstarMessage removes the \Flagged flag from a message.
-func (ic *IMAPClient) UnstarMessage(folder string, uid uint32) error {
-	return ic.SetFlags(folder, uid, []imap.Flag{imap.FlagFlagged}, false)
+// UnstarMessages removes the \Flagged flag from messages.
+func (ic *IMAPClient) UnstarMessages(folder string, uids imap.UIDSet) error {
+	return ic.SetFlags(folder, uids, []imap.Flag{imap.FlagFlagged}, false)
 }
 
-// MarkRead adds the \Seen flag to a message.
-func (ic *IMAPClient) MarkRead(folder string, uid uint32) error {
-	return ic.SetFlags(folder, uid, []imap.Flag{imap.FlagSeen}, true)
+// MarkRead adds the \Seen flag to messages.
+func (ic *IMAPClient) MarkRead(folder string, uids imap.UIDSet) error {
+	return ic.SetFlags(folder, uids, []imap.Flag{imap.FlagSeen}, true)
 }
 
-// MarkUnread removes the \Seen flag from a message.
-func (ic *IMAPClient) MarkUnread(folder string, uid uint32) error {
-	return ic.SetFlags(folder, uid, []imap.Flag{imap.FlagSeen}, false)
+// MarkUnread removes the \Seen flag from messages.
+func (ic *IMAPClient) MarkUnread(folder string, uids imap.UIDSet) error {
+	return ic.SetFlags(folder, uids, []imap.Flag{imap.FlagSeen}, false)
 }
 
 // messageFromFetchData extracts a Message from IMAP fetch data.
@@ -373,6 +863,12 @@ func messageFromFetchData(msg *imapclient.FetchMessageData) Message {
 			m.To = envelopeAddresses(env.To)
 			m.Cc = envelopeAddresses(env.Cc)
 			m.ReplyTo = envelopeAddresses(env.ReplyTo)
+		case imapclient.FetchItemDataRFC822Size:
+			m.Size = data.Size
+		case imapclient.FetchItemDataBodyStructure:
+			m.bodyStructure = data.BodyStructure
+			m.AttachmentCount = len(attachmentsFromBodyStructure(data.BodyStructure))
+			m.HasAttachments = m.AttachmentCount > 0
 		case imapclient.FetchItemDataBodySection:
 			body, err := io.ReadAll(data.Literal)
 			if err != nil {
@@ -382,7 +878,10 @@ func messageFromFetchData(msg *imapclient.FetchMessageData) Message {
 			if err == nil {
 				m.Body = parsed.Body
 				m.HTMLBody = parsed.HTMLBody
+				m.BodyFromHTML = parsed.BodyFromHTML
 				m.Attachments = parsed.Attachments
+				m.AttachmentCount = len(parsed.Attachments)
+				m.HasAttachments = m.AttachmentCount > 0
 				if parsed.InReplyTo != "" {
 					m.InReplyTo = parsed.InReplyTo
 				}
@@ -415,6 +914,15 @@ func flagsToStrings(flags []imap.Flag) []string {
 	return result
 }
 
+func containsMailboxAttr(attrs []imap.MailboxAttr, attr imap.MailboxAttr) bool {
+	for _, a := range attrs {
+		if a == attr {
+			return true
+		}
+	}
+	return false
+}
+
 func containsFlag(flags []imap.Flag, flag imap.Flag) bool {
 	for _, f := range flags {
 		if f == flag {
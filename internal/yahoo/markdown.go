@@ -0,0 +1,531 @@
+package yahoo
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// MarkdownToHTML renders Markdown as a complete HTML document for an email
+// body. It supports headings, paragraphs, emphasis, code, block quotes,
+// nested lists, rules, links, images and pipe tables. Line breaks inside a
+// paragraph are kept, as in a plain text email.
+//
+// The output is safe to send: raw HTML in the source is escaped as text, and
+// links and images are only kept for http, https and mailto URLs.
+func MarkdownToHTML(src string) string {
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\t", "    "), "\n")
+
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n</head>\n<body>\n")
+	renderMarkdownBlocks(&b, lines)
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+var (
+	mdHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	mdRule      = regexp.MustCompile(`^ {0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdFence     = regexp.MustCompile("^ {0,3}(```+|~~~+)\\s*([^`\\s]*)")
+	mdQuote     = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	mdListItem  = regexp.MustCompile(`^( *)([-*+]|\d{1,9}[.)])( +|$)(.*)$`)
+	mdTableRule = regexp.MustCompile(`^ *\|? *:?-+:? *(\| *:?-+:? *)*\|? *$`)
+)
+
+// Inline styles for tables, since many mail clients drop style sheets.
+const (
+	mdTableStyle = `border-collapse:collapse`
+	mdCellStyle  = `border:1px solid #d0d7de;padding:4px 8px`
+)
+
+// renderMarkdownBlocks renders a sequence of block-level lines.
+func renderMarkdownBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case mdFence.MatchString(line):
+			m := mdFence.FindStringSubmatch(line)
+			fence := m[1]
+			i++
+			var code []string
+			for ; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					i++
+					break
+				}
+				code = append(code, lines[i])
+			}
+			if m[2] != "" {
+				fmt.Fprintf(b, "<pre><code class=\"language-%s\">", html.EscapeString(m[2]))
+			} else {
+				b.WriteString("<pre><code>")
+			}
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			b.WriteString("</code></pre>\n")
+
+		case mdHeading.MatchString(line):
+			m := mdHeading.FindStringSubmatch(line)
+			level := len(m[1])
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", level, renderMarkdownInline(m[2]), level)
+			i++
+
+		case mdRule.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+
+		case mdQuote.MatchString(line):
+			var quoted []string
+			for ; i < len(lines) && mdQuote.MatchString(lines[i]); i++ {
+				quoted = append(quoted, mdQuote.FindStringSubmatch(lines[i])[1])
+			}
+			b.WriteString("<blockquote>\n")
+			renderMarkdownBlocks(b, quoted)
+			b.WriteString("</blockquote>\n")
+
+		case mdListItem.MatchString(line):
+			i = renderMarkdownList(b, lines, i)
+
+		case isMarkdownTable(lines, i):
+			i = renderMarkdownTable(b, lines, i)
+
+		default:
+			var para []string
+			for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				if len(para) > 0 && startsMarkdownBlock(lines, i) {
+					break
+				}
+				para = append(para, strings.TrimSpace(lines[i]))
+			}
+			b.WriteString("<p>")
+			b.WriteString(renderMarkdownLines(para))
+			b.WriteString("</p>\n")
+		}
+	}
+}
+
+// startsMarkdownBlock reports whether lines[i] interrupts a paragraph.
+func startsMarkdownBlock(lines []string, i int) bool {
+	line := lines[i]
+	return mdFence.MatchString(line) || mdHeading.MatchString(line) || mdRule.MatchString(line) ||
+		mdQuote.MatchString(line) || mdListItem.MatchString(line) || isMarkdownTable(lines, i)
+}
+
+// renderMarkdownLines renders the lines of a paragraph, keeping line breaks.
+func renderMarkdownLines(lines []string) string {
+	rendered := make([]string, len(lines))
+	for i, l := range lines {
+		rendered[i] = renderMarkdownInline(strings.TrimSuffix(l, "\\"))
+	}
+	return strings.Join(rendered, "<br>\n")
+}
+
+// renderMarkdownList renders the list starting at lines[start] and returns
+// the index of the first line after it. Lines indented past an item's
+// marker belong to that item, which may hold nested blocks.
+func renderMarkdownList(b *strings.Builder, lines []string, start int) int {
+	first := mdListItem.FindStringSubmatch(lines[start])
+	indent := len(first[1])
+	kind := first[2][len(first[2])-1] // the bullet, or the "." or ")" after a number
+	ordered := kind == '.' || kind == ')'
+
+	if ordered {
+		if n := strings.TrimRight(first[2], ".)"); n != "1" {
+			fmt.Fprintf(b, "<ol start=\"%s\">\n", strings.TrimLeft(n, "0"))
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+
+	i := start
+	for i < len(lines) {
+		m := mdListItem.FindStringSubmatch(lines[i])
+		if m == nil || len(m[1]) != indent || m[2][len(m[2])-1] != kind {
+			break
+		}
+		content := len(m[1]) + len(m[2]) + len(m[3])
+		item := []string{m[4]}
+		i++
+
+		loose := false
+		for i < len(lines) {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				// A blank line continues the item only if indented text follows.
+				if i+1 < len(lines) && leadingSpaces(lines[i+1]) > indent && strings.TrimSpace(lines[i+1]) != "" {
+					item = append(item, "")
+					loose = true
+					i++
+					continue
+				}
+				break
+			}
+			if leadingSpaces(line) <= indent {
+				if mdListItem.MatchString(line) || startsMarkdownBlock(lines, i) {
+					break
+				}
+			}
+			item = append(item, trimIndent(line, content))
+			i++
+		}
+
+		b.WriteString("<li>")
+		if loose {
+			b.WriteString("\n")
+			renderMarkdownBlocks(b, item)
+		} else {
+			// A tight item starts with its text, without a paragraph.
+			text := 1
+			for text < len(item) && !startsMarkdownBlock(item, text) {
+				text++
+			}
+			b.WriteString(renderMarkdownLines(trimLines(item[:text])))
+			if text < len(item) {
+				b.WriteString("\n")
+				renderMarkdownBlocks(b, item[text:])
+			}
+		}
+		b.WriteString("</li>\n")
+
+		// Blank lines between items keep the list going.
+		j := i
+		for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+			j++
+		}
+		if j > i && j < len(lines) {
+			if m := mdListItem.FindStringSubmatch(lines[j]); m != nil && len(m[1]) == indent && m[2][len(m[2])-1] == kind {
+				i = j
+			}
+		}
+	}
+
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+// isMarkdownTable reports whether a pipe table starts at lines[i]: a row
+// followed by a delimiter row such as "| --- | :-: |".
+func isMarkdownTable(lines []string, i int) bool {
+	return i+1 < len(lines) && strings.Contains(lines[i], "|") &&
+		strings.Contains(lines[i+1], "-") && mdTableRule.MatchString(lines[i+1])
+}
+
+// renderMarkdownTable renders the pipe table starting at lines[start] and
+// returns the index of the first line after it.
+func renderMarkdownTable(b *strings.Builder, lines []string, start int) int {
+	header := splitTableRow(lines[start])
+	var aligns []string
+	for _, cell := range splitTableRow(lines[start+1]) {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	writeRow := func(cells []string, tag string) {
+		b.WriteString("<tr>")
+		for j := range header {
+			cell := ""
+			if j < len(cells) {
+				cell = cells[j]
+			}
+			style := mdCellStyle
+			if j < len(aligns) && aligns[j] != "" {
+				style += ";text-align:" + aligns[j]
+			}
+			fmt.Fprintf(b, "<%s style=\"%s\">%s</%s>", tag, style, renderMarkdownInline(cell), tag)
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	fmt.Fprintf(b, "<table style=\"%s\">\n<thead>\n", mdTableStyle)
+	writeRow(header, "th")
+	b.WriteString("</thead>\n<tbody>\n")
+	i := start + 2
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && strings.Contains(lines[i], "|"); i++ {
+		writeRow(splitTableRow(lines[i]), "td")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+// splitTableRow splits a table row into trimmed cells. "\|" is a literal
+// pipe.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// leadingSpaces counts the spaces at the start of a line.
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// trimIndent removes up to n leading spaces.
+func trimIndent(line string, n int) string {
+	if s := leadingSpaces(line); s < n {
+		n = s
+	}
+	return line[n:]
+}
+
+// trimLines trims the space around each line.
+func trimLines(lines []string) []string {
+	trimmed := make([]string, len(lines))
+	for i, l := range lines {
+		trimmed[i] = strings.TrimSpace(l)
+	}
+	return trimmed
+}
+
+// mdEscapable are the characters a backslash makes literal.
+const mdEscapable = "\\`*_{}[]()#+-.!|~<>"
+
+// renderMarkdownInline renders emphasis, code, links and images in a line of
+// text and escapes everything else.
+func renderMarkdownInline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(mdEscapable, s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			n := countRun(s[i:], '`')
+			fence := s[i : i+n]
+			if end := strings.Index(s[i+n:], fence); end >= 0 {
+				code := strings.TrimSpace(s[i+n : i+n+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += n + end + n
+				continue
+			}
+			b.WriteString(fence)
+			i += n
+			continue
+
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if text, url, n, ok := parseMarkdownLink(s[i+1:]); ok {
+				if safeURL(url) {
+					fmt.Fprintf(&b, "<img src=\"%s\" alt=\"%s\">", html.EscapeString(url), html.EscapeString(text))
+				} else {
+					b.WriteString(html.EscapeString(text))
+				}
+				i += 1 + n
+				continue
+			}
+
+		case c == '[':
+			if text, url, n, ok := parseMarkdownLink(s[i:]); ok {
+				if safeURL(url) {
+					fmt.Fprintf(&b, "<a href=\"%s\">%s</a>", html.EscapeString(url), renderMarkdownInline(text))
+				} else {
+					b.WriteString(renderMarkdownInline(text))
+				}
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				url := s[i+1 : i+end]
+				if !strings.ContainsAny(url, " <") && safeURL(url) && strings.Contains(url, ":") {
+					fmt.Fprintf(&b, "<a href=\"%s\">%s</a>", html.EscapeString(url), html.EscapeString(strings.TrimPrefix(url, "mailto:")))
+					i += end + 1
+					continue
+				}
+			}
+
+		case c == 'h' && (strings.HasPrefix(s[i:], "http://") || strings.HasPrefix(s[i:], "https://")) &&
+			(i == 0 || !isWordByte(s[i-1])):
+			url := bareURL(s[i:])
+			fmt.Fprintf(&b, "<a href=\"%s\">%s</a>", html.EscapeString(url), html.EscapeString(url))
+			i += len(url)
+			continue
+
+		case c == '*' || c == '_' || c == '~':
+			n := countRun(s[i:], c)
+			if n > 3 {
+				break
+			}
+			if c == '~' && n != 2 {
+				break
+			}
+			// Underscores inside words are literal.
+			if c == '_' && i > 0 && isWordByte(s[i-1]) {
+				break
+			}
+			delim := s[i : i+n]
+			if end := findClosingDelim(s[i+n:], delim); end > 0 {
+				inner := renderMarkdownInline(s[i+n : i+n+end])
+				switch {
+				case c == '~':
+					inner = "<del>" + inner + "</del>"
+				case n == 1:
+					inner = "<em>" + inner + "</em>"
+				case n == 2:
+					inner = "<strong>" + inner + "</strong>"
+				default:
+					inner = "<strong><em>" + inner + "</em></strong>"
+				}
+				b.WriteString(inner)
+				i += n + end + n
+				continue
+			}
+			b.WriteString(delim)
+			i += n
+			continue
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// parseMarkdownLink parses "[text](url)" or "[text](url "title")" at the
+// start of s and returns its length.
+func parseMarkdownLink(s string) (text, url string, n int, ok bool) {
+	depth := 0
+	closeText := -1
+	for i := 0; i < len(s) && closeText < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeText = i
+			}
+		}
+	}
+	if closeText < 0 || !strings.HasPrefix(s[closeText+1:], "(") {
+		return "", "", 0, false
+	}
+
+	// The target ends at the ")" that balances the opening one.
+	end := -1
+	depth = 0
+	for i := closeText + 1; i < len(s) && end < 0; i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return "", "", 0, false
+	}
+	target := strings.TrimSpace(s[closeText+2 : end])
+	if sp := strings.IndexAny(target, " \t"); sp >= 0 {
+		target = target[:sp] // drop the title
+	}
+	target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+	return s[1:closeText], target, end + 1, true
+}
+
+// findClosingDelim returns the index in s of a closing emphasis delimiter,
+// or -1. A closing delimiter is not preceded by a space and is not part of
+// a longer run of the same character.
+func findClosingDelim(s, delim string) int {
+	if s == "" || s[0] == ' ' {
+		return -1
+	}
+	for i := 1; i+len(delim) <= len(s); i++ {
+		if s[i] == '`' {
+			// Skip code spans.
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				i += end + 1
+				continue
+			}
+		}
+		if strings.HasPrefix(s[i:], delim) && s[i-1] != ' ' && s[i-1] != delim[0] &&
+			(i+len(delim) == len(s) || s[i+len(delim)] != delim[0]) {
+			if delim[0] == '_' && i+len(delim) < len(s) && isWordByte(s[i+len(delim)]) {
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// bareURL returns the URL at the start of s, without trailing punctuation.
+func bareURL(s string) string {
+	end := strings.IndexAny(s, " <>\"")
+	if end < 0 {
+		end = len(s)
+	}
+	url := s[:end]
+	for len(url) > 0 && strings.ContainsRune(".,:;!?)'*_", rune(url[len(url)-1])) {
+		if url[len(url)-1] == ')' && strings.Count(url, "(") >= strings.Count(url, ")") {
+			break
+		}
+		url = url[:len(url)-1]
+	}
+	return url
+}
+
+// safeURL reports whether a link target may be included in a message.
+func safeURL(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") ||
+		strings.HasPrefix(lower, "mailto:")
+}
+
+// countRun counts the repetitions of c at the start of s.
+func countRun(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+// isWordByte reports whether c is an ASCII letter or digit.
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
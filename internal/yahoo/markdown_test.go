@@ -0,0 +1,157 @@
+package yahoo
+
+import (
+	"strings"
+	"testing"
+)
+
+// markdownBody renders src and returns what MarkdownToHTML puts in <body>.
+func markdownBody(src string) string {
+	out := MarkdownToHTML(src)
+	start := strings.Index(out, "<body>\n") + len("<body>\n")
+	end := strings.Index(out, "</body>")
+	return out[start:end]
+}
+
+func TestMarkdownToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "raw script",
+			src:  "<script>alert(1)</script>",
+			want: "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n",
+		},
+		{
+			name: "raw img onerror",
+			src:  `<img src=x onerror="alert(1)">`,
+			want: "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>\n",
+		},
+		{
+			name: "javascript link",
+			src:  "[x](javascript:alert(1))",
+			want: "<p>x</p>\n",
+		},
+		{
+			name: "mixed-case javascript link",
+			src:  "[x](JaVaScRiPt:alert(1))",
+			want: "<p>x</p>\n",
+		},
+		{
+			name: "entity-encoded javascript link",
+			src:  "[x](&#106;avascript:alert(1))",
+			want: "<p>x</p>\n",
+		},
+		{
+			name: "javascript image",
+			src:  "![i](javascript:alert(1))",
+			want: "<p>i</p>\n",
+		},
+		{
+			name: "data image",
+			src:  "![i](data:image/png;base64,AAAA)",
+			want: "<p>i</p>\n",
+		},
+		{
+			name: "javascript autolink",
+			src:  "<javascript:alert(1)>",
+			want: "<p>&lt;javascript:alert(1)&gt;</p>\n",
+		},
+		{
+			name: "link attribute is escaped",
+			src:  `[ok](https://example.com/?a=1&b="2")`,
+			want: "<p><a href=\"https://example.com/?a=1&amp;b=&#34;2&#34;\">ok</a></p>\n",
+		},
+		{
+			name: "mailto autolink",
+			src:  "<mailto:a@example.com>",
+			want: "<p><a href=\"mailto:a@example.com\">a@example.com</a></p>\n",
+		},
+		{
+			name: "image",
+			src:  "![logo](https://example.com/logo.png)",
+			want: "<p><img src=\"https://example.com/logo.png\" alt=\"logo\"></p>\n",
+		},
+		{
+			name: "fenced code",
+			src:  "```go\nif a < b {\n  *x*\n}\n```",
+			want: "<pre><code class=\"language-go\">if a &lt; b {\n  *x*\n}</code></pre>\n",
+		},
+		{
+			name: "unclosed fence",
+			src:  "~~~\n<b>",
+			want: "<pre><code>&lt;b&gt;</code></pre>\n",
+		},
+		{
+			name: "nested lists",
+			src:  "- a\n  - b\n    - c\n- d",
+			want: "<ul>\n<li>a\n<ul>\n<li>b\n<ul>\n<li>c</li>\n</ul>\n</li>\n</ul>\n</li>\n<li>d</li>\n</ul>\n",
+		},
+		{
+			name: "ordered list with nested bullets",
+			src:  "3. three\n4. four\n   - sub",
+			want: "<ol start=\"3\">\n<li>three</li>\n<li>four\n<ul>\n<li>sub</li>\n</ul>\n</li>\n</ol>\n",
+		},
+		{
+			name: "table",
+			src:  "| A | B |\n|:--|--:|\n| 1 | *2* |",
+			want: "<table style=\"border-collapse:collapse\">\n<thead>\n" +
+				"<tr><th style=\"border:1px solid #d0d7de;padding:4px 8px;text-align:left\">A</th>" +
+				"<th style=\"border:1px solid #d0d7de;padding:4px 8px;text-align:right\">B</th></tr>\n" +
+				"</thead>\n<tbody>\n" +
+				"<tr><td style=\"border:1px solid #d0d7de;padding:4px 8px;text-align:left\">1</td>" +
+				"<td style=\"border:1px solid #d0d7de;padding:4px 8px;text-align:right\"><em>2</em></td></tr>\n" +
+				"</tbody>\n</table>\n",
+		},
+		{
+			name: "unclosed emphasis",
+			src:  "*unclosed and **also",
+			want: "<p>*unclosed and **also</p>\n",
+		},
+		{
+			name: "emphasis",
+			src:  "*a* **b** ***c*** ~~d~~",
+			want: "<p><em>a</em> <strong>b</strong> <strong><em>c</em></strong> <del>d</del></p>\n",
+		},
+		{
+			name: "underscores inside words",
+			src:  "snake_case_name",
+			want: "<p>snake_case_name</p>\n",
+		},
+		{
+			name: "line breaks are kept",
+			src:  "a\nb",
+			want: "<p>a<br>\nb</p>\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownBody(tt.src); got != tt.want {
+				t.Errorf("MarkdownToHTML(%q) body =\n%q\nwant\n%q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com":    true,
+		"HTTP://EXAMPLE.COM":     true,
+		"mailto:a@example.com":   true,
+		"javascript:alert(1)":    false,
+		"JavaScript:alert(1)":    false,
+		" javascript:alert(1)":   false,
+		"&#106;avascript:x":      false,
+		"data:text/html,<b>":     false,
+		"vbscript:msgbox":        false,
+		"//example.com/relative": false,
+	}
+	for url, want := range tests {
+		if got := safeURL(url); got != want {
+			t.Errorf("safeURL(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
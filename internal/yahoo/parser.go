@@ -2,12 +2,16 @@ package yahoo
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"mime"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/emersion/go-message"
 	_ "github.com/emersion/go-message/charset"
 	"github.com/emersion/go-message/mail"
 )
@@ -20,9 +24,73 @@ func ParseMessage(r io.Reader) (*Message, error) {
 	}
 	defer mr.Close()
 
-	header := mr.Header
 	msg := &Message{}
+	parseHeader(mr.Header, msg)
 
+	// Parse body parts.
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+
+		switch h := part.Header.(type) {
+		case *mail.InlineHeader:
+			ct, _, _ := h.ContentType()
+			body, err := io.ReadAll(part.Body)
+			if err != nil {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(ct, "text/plain"):
+				msg.Body = string(body)
+			case strings.HasPrefix(ct, "text/html"):
+				msg.HTMLBody = string(body)
+			}
+		case *mail.AttachmentHeader:
+			filename, _ := h.Filename()
+			ct, _, _ := h.ContentType()
+			size, err := io.Copy(io.Discard, part.Body)
+			if err != nil {
+				continue
+			}
+			msg.Attachments = append(msg.Attachments, Attachment{
+				Index:       len(msg.Attachments) + 1,
+				Filename:    filename,
+				ContentType: ct,
+				Size:        int(size),
+			})
+		}
+	}
+
+	// If no plain text body, render the HTML body as text.
+	if msg.Body == "" && msg.HTMLBody != "" {
+		msg.Body = HTMLToText(msg.HTMLBody)
+		msg.BodyFromHTML = true
+	}
+
+	return msg, nil
+}
+
+// ParseMessageHeader parses only the header of a raw email message. The body
+// is not read.
+func ParseMessageHeader(r io.Reader) (*Message, error) {
+	mr, err := mail.CreateReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("creating mail reader: %w", err)
+	}
+	defer mr.Close()
+
+	msg := &Message{}
+	parseHeader(mr.Header, msg)
+	return msg, nil
+}
+
+// parseHeader fills the header fields of msg from header.
+func parseHeader(header mail.Header, msg *Message) {
 	// Parse From.
 	if addrs, err := header.AddressList("From"); err == nil && len(addrs) > 0 {
 		msg.From = Address{Name: addrs[0].Name, Address: addrs[0].Address}
@@ -77,51 +145,6 @@ func ParseMessage(r io.Reader) (*Message, error) {
 		refs := strings.Fields(v)
 		msg.References = refs
 	}
-
-	// Parse body parts.
-	for {
-		part, err := mr.NextPart()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			continue
-		}
-
-		switch h := part.Header.(type) {
-		case *mail.InlineHeader:
-			ct, _, _ := h.ContentType()
-			body, err := io.ReadAll(part.Body)
-			if err != nil {
-				continue
-			}
-			switch {
-			case strings.HasPrefix(ct, "text/plain"):
-				msg.Body = string(body)
-			case strings.HasPrefix(ct, "text/html"):
-				msg.HTMLBody = string(body)
-			}
-		case *mail.AttachmentHeader:
-			filename, _ := h.Filename()
-			ct, _, _ := h.ContentType()
-			body, err := io.ReadAll(part.Body)
-			if err != nil {
-				continue
-			}
-			msg.Attachments = append(msg.Attachments, Attachment{
-				Filename:    filename,
-				ContentType: ct,
-				Size:        len(body),
-			})
-		}
-	}
-
-	// If no plain text body, use HTML body.
-	if msg.Body == "" && msg.HTMLBody != "" {
-		msg.Body = msg.HTMLBody
-	}
-
-	return msg, nil
 }
 
 // ComposeMessage creates a raw email message from SendOptions.
@@ -147,7 +170,11 @@ func ComposeMessage(opts *SendOptions) ([]byte, error) {
 
 	h.SetSubject(opts.Subject)
 	h.SetDate(time.Now())
-	h.SetContentType("text/plain", map[string]string{"charset": "UTF-8"})
+
+	if opts.MessageID == "" {
+		opts.MessageID = GenerateMessageID(opts.From)
+	}
+	h.Set("Message-ID", FormatMessageID(opts.MessageID))
 
 	if opts.ReplyTo != "" {
 		h.Set("In-Reply-To", opts.ReplyTo)
@@ -157,13 +184,63 @@ func ComposeMessage(opts *SendOptions) ([]byte, error) {
 		h.Set(k, v)
 	}
 
-	mw, err := mail.CreateSingleInlineWriter(&buf, h)
+	// Without attachments the message is a single text/plain entity, or a
+	// multipart/alternative one with HTML.
+	if len(opts.Attachments) == 0 && len(opts.Parts) == 0 && opts.Forward == nil {
+		if opts.HTMLBody != "" {
+			h.SetContentType("multipart/alternative", nil)
+		} else {
+			setTextHeader(&h.Header)
+		}
+		mw, err := message.CreateWriter(&buf, h.Header)
+		if err != nil {
+			return nil, fmt.Errorf("creating mail writer: %w", err)
+		}
+		if opts.HTMLBody != "" {
+			err = writeAlternatives(mw, opts.Body, opts.HTMLBody)
+		} else {
+			_, err = io.WriteString(mw, opts.Body)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("writing body: %w", err)
+		}
+		if err := mw.Close(); err != nil {
+			return nil, fmt.Errorf("closing mail writer: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	h.SetContentType("multipart/mixed", nil)
+	mw, err := message.CreateWriter(&buf, h.Header)
 	if err != nil {
 		return nil, fmt.Errorf("creating mail writer: %w", err)
 	}
 
-	if _, err := io.WriteString(mw, opts.Body); err != nil {
-		return nil, fmt.Errorf("writing body: %w", err)
+	if opts.HTMLBody != "" {
+		err = writeAlternativePart(mw, opts.Body, opts.HTMLBody)
+	} else {
+		err = writeTextPart(mw, opts.Body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Forward != nil {
+		if err := writeForwardedMessage(mw, opts.Forward); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range opts.Attachments {
+		if err := writeAttachmentFile(mw, path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range opts.Parts {
+		if err := writePart(mw, p); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := mw.Close(); err != nil {
@@ -173,6 +250,84 @@ func ComposeMessage(opts *SendOptions) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// GenerateMessageID returns a new unique message ID on the domain of the
+// from address, without angle brackets.
+func GenerateMessageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return fmt.Sprintf("%s.%s@%s", strconv.FormatInt(time.Now().UnixNano(), 36), hex.EncodeToString(b[:]), domain)
+}
+
+// setTextHeader sets the content headers of a UTF-8 text/plain entity.
+func setTextHeader(h *message.Header) {
+	h.SetContentType("text/plain", map[string]string{"charset": "UTF-8"})
+	h.Set("Content-Transfer-Encoding", "quoted-printable")
+}
+
+// writeTextPart writes body as an inline text/plain part of a multipart message.
+func writeTextPart(mw *message.Writer, body string) error {
+	var h message.Header
+	setTextHeader(&h)
+	h.Set("Content-Disposition", "inline")
+
+	pw, err := mw.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("creating text part: %w", err)
+	}
+	if _, err := io.WriteString(pw, body); err != nil {
+		return fmt.Errorf("writing body: %w", err)
+	}
+	return pw.Close()
+}
+
+// writeAlternativePart writes text and HTML versions of the body as a
+// multipart/alternative part of a multipart message.
+func writeAlternativePart(mw *message.Writer, text, html string) error {
+	var h message.Header
+	h.SetContentType("multipart/alternative", nil)
+
+	aw, err := mw.CreatePart(h)
+	if err != nil {
+		return fmt.Errorf("creating alternative part: %w", err)
+	}
+	if err := writeAlternatives(aw, text, html); err != nil {
+		aw.Close()
+		return err
+	}
+	return aw.Close()
+}
+
+// writeAlternatives writes the text/plain and text/html parts of a
+// multipart/alternative entity, plainest first as RFC 2046 requires.
+func writeAlternatives(mw *message.Writer, text, html string) error {
+	parts := []struct {
+		subtype, body string
+	}{{"plain", text}, {"html", html}}
+
+	for _, p := range parts {
+		var h message.Header
+		h.SetContentType("text/"+p.subtype, map[string]string{"charset": "UTF-8"})
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return fmt.Errorf("creating text/%s part: %w", p.subtype, err)
+		}
+		if _, err := io.WriteString(pw, p.body); err != nil {
+			pw.Close()
+			return fmt.Errorf("writing text/%s part: %w", p.subtype, err)
+		}
+		if err := pw.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // DecodeRFC2047 decodes RFC 2047 encoded words in a string.
 func DecodeRFC2047(s string) string {
 	dec := new(mime.WordDecoder)
@@ -0,0 +1,192 @@
+package yahoo
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/emersion/go-message"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+const (
+	// previewLength is the maximum length of a preview, in bytes.
+	previewLength = 200
+	// previewFetchSize is how much of a text/plain part is fetched. It
+	// leaves room for transfer encoding.
+	previewFetchSize = 1024
+	// previewHTMLFetchSize is how much of a text/html part is fetched.
+	// Newsletters often start with several KiB of head and style before the
+	// first visible text.
+	previewHTMLFetchSize = 16 << 10
+)
+
+// previewPart is the text part of one message used for its preview.
+type previewPart struct {
+	index int // into the messages slice
+	part  *imap.BodyStructureSinglePart
+}
+
+// previewGroup is a part path and fetch size shared by several messages.
+type previewGroup struct {
+	path string
+	size int64
+}
+
+// fetchSize returns how much of part to fetch for a preview.
+func fetchSize(part *imap.BodyStructureSinglePart) int64 {
+	if strings.EqualFold(part.Subtype, "html") {
+		return previewHTMLFetchSize
+	}
+	return previewFetchSize
+}
+
+// addPreviews sets the Preview of messages in the selected folder from the
+// start of their first text part. Messages are fetched in one command per
+// distinct part path and fetch size.
+func (ic *IMAPClient) addPreviews(messages []Message) error {
+	groups := map[previewGroup][]previewPart{}
+	for i := range messages {
+		if messages[i].bodyStructure == nil {
+			continue
+		}
+		path, part := findPreviewPart(messages[i].bodyStructure)
+		if part == nil {
+			continue
+		}
+		key := previewGroup{path: formatPartPath(path), size: fetchSize(part)}
+		groups[key] = append(groups[key], previewPart{index: i, part: part})
+	}
+
+	for key, parts := range groups {
+		path, err := parsePartPath(key.path)
+		if err != nil {
+			return err
+		}
+
+		byUID := make(map[imap.UID]previewPart, len(parts))
+		var uidSet imap.UIDSet
+		for _, p := range parts {
+			uid := imap.UID(messages[p.index].UID)
+			byUID[uid] = p
+			uidSet.AddNum(uid)
+		}
+
+		fetchOptions := &imap.FetchOptions{
+			UID: true,
+			BodySection: []*imap.FetchItemBodySection{{
+				Part:    path,
+				Peek:    true,
+				Partial: &imap.SectionPartial{Offset: 0, Size: key.size},
+			}},
+		}
+
+		fetchCmd := ic.client.Fetch(uidSet, fetchOptions)
+		for {
+			msg := fetchCmd.Next()
+			if msg == nil {
+				break
+			}
+
+			var uid imap.UID
+			var raw []byte
+			for {
+				item := msg.Next()
+				if item == nil {
+					break
+				}
+				switch data := item.(type) {
+				case imapclient.FetchItemDataUID:
+					uid = data.UID
+				case imapclient.FetchItemDataBodySection:
+					raw, _ = io.ReadAll(data.Literal)
+				}
+			}
+
+			if p, ok := byUID[uid]; ok && raw != nil {
+				messages[p.index].Preview = decodePreview(raw, p.part)
+			}
+		}
+		if err := fetchCmd.Close(); err != nil {
+			return yoyerrors.FromIMAPError(err)
+		}
+	}
+
+	return nil
+}
+
+// findPreviewPart returns the first text/plain part of a message that is not
+// an attachment, or else its first text/html part.
+func findPreviewPart(bs imap.BodyStructure) ([]int, *imap.BodyStructureSinglePart) {
+	var htmlPath []int
+	var htmlPart, plainPart *imap.BodyStructureSinglePart
+	var plainPath []int
+
+	bs.Walk(func(path []int, part imap.BodyStructure) bool {
+		single, ok := part.(*imap.BodyStructureSinglePart)
+		if !ok {
+			return plainPart == nil
+		}
+		if !strings.EqualFold(single.Type, "text") || isAttachmentPart(single) {
+			return true
+		}
+		switch strings.ToLower(single.Subtype) {
+		case "plain":
+			if plainPart == nil {
+				plainPath, plainPart = append([]int(nil), path...), single
+			}
+		case "html":
+			if htmlPart == nil {
+				htmlPath, htmlPart = append([]int(nil), path...), single
+			}
+		}
+		return true
+	})
+
+	if plainPart != nil {
+		return plainPath, plainPart
+	}
+	return htmlPath, htmlPart
+}
+
+// decodePreview decodes the start of a text part and collapses it to a single
+// line. The input may be cut anywhere, including inside an encoded sequence.
+func decodePreview(raw []byte, part *imap.BodyStructureSinglePart) string {
+	params := map[string]string{}
+	if charset := part.Params["charset"]; charset != "" {
+		params["charset"] = charset
+	}
+
+	var h message.Header
+	h.SetContentType(part.MediaType(), params)
+	h.Set("Content-Transfer-Encoding", part.Encoding)
+
+	text := string(raw)
+	if e, err := message.New(h, strings.NewReader(text)); err == nil || message.IsUnknownCharset(err) {
+		// A truncated encoding ends in an error; keep what was decoded.
+		decoded, _ := io.ReadAll(e.Body)
+		text = string(decoded)
+	}
+	text = strings.ToValidUTF8(text, "")
+
+	if strings.EqualFold(part.Subtype, "html") {
+		text = htmlToText(text, false)
+	}
+
+	text = strings.Join(strings.Fields(text), " ")
+	return truncateUTF8(text, previewLength)
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,45 @@
+package yahoo
+
+import (
+	"fmt"
+	"mime/quotedprintable"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-imap/v2"
+)
+
+func TestDecodePreviewNewsletter(t *testing.T) {
+	// A typical newsletter: a long head with inline styles, a hidden
+	// preheader and table layout before the first visible text.
+	var style strings.Builder
+	for i := range 150 {
+		fmt.Fprintf(&style, ".c%d { font-family: Helvetica, Arial, sans-serif; color: #333333; }\n", i)
+	}
+	html := `<!DOCTYPE html><html><head><meta charset="utf-8"><title>Weekly digest</title>` +
+		"<style type=\"text/css\">\n" + style.String() + "</style></head>" +
+		`<body><div style="display:none">Preheader text</div>` +
+		`<table width="100%"><tr><td class="c1"><h1>This week in Go</h1>` +
+		`<p>Five things you missed.</p></td></tr></table></body></html>`
+
+	var encoded strings.Builder
+	w := quotedprintable.NewWriter(&encoded)
+	w.Write([]byte(html))
+	w.Close()
+
+	part := &imap.BodyStructureSinglePart{
+		Type:     "text",
+		Subtype:  "html",
+		Params:   map[string]string{"charset": "utf-8"},
+		Encoding: "quoted-printable",
+	}
+	raw := encoded.String()
+	if size := int(fetchSize(part)); len(raw) > size {
+		raw = raw[:size]
+	}
+
+	got := decodePreview([]byte(raw), part)
+	if !strings.HasPrefix(got, "This week in Go") {
+		t.Errorf("decodePreview() = %q, want it to start with %q", got, "This week in Go")
+	}
+}
@@ -0,0 +1,475 @@
+package yahoo
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/emersion/go-imap/v2"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+// ParseQuery compiles a Gmail-like search query into IMAP search criteria.
+//
+// Terms are ANDed together unless joined with OR, and can be negated with
+// NOT or a leading "-". Parentheses group terms. Supported fields:
+//
+//	from: to: cc: subject: body:     substring of a header or the body
+//	before: after: on:               date as YYYY-MM-DD; before: excludes the
+//	                                 day, after: includes it
+//	larger: smaller:                 size in bytes, or with a K, M or G suffix
+//	is:unread is:read is:flagged is:answered
+//	has:attachment
+//	uid:101,105,200:250
+//
+// A bare word or "quoted phrase" matches the Subject or From header.
+func ParseQuery(query string) (*imap.SearchCriteria, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, yoyerrors.New("empty search query", yoyerrors.ExitInvalidInput)
+	}
+
+	p := &queryParser{query: query, tokens: tokens}
+
+	criteria, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != nil {
+		if tok.kind == tokenRParen {
+			return nil, p.errorAt(*tok, "unmatched \")\"")
+		}
+		return nil, p.errorAt(*tok, fmt.Sprintf("unexpected %q", tok.text))
+	}
+
+	return criteria, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenLParen
+	tokenRParen
+	tokenMinus
+)
+
+// queryToken is a lexical token of a search query. pos and end are byte
+// offsets into the query, used to point at the token in errors.
+type queryToken struct {
+	kind  tokenKind
+	text  string
+	field string // for words of the form field:value
+	pos   int
+	end   int
+}
+
+// lexQuery splits a query into tokens.
+func lexQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(query) {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokenLParen, text: "(", pos: i, end: i + 1})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokenRParen, text: ")", pos: i, end: i + 1})
+			i++
+		case r == '-' && i+1 < len(query) && !isQueryDelimiter(query[i+1]):
+			tokens = append(tokens, queryToken{kind: tokenMinus, text: "-", pos: i, end: i + 1})
+			i++
+		case r == '"':
+			text, end, err := lexPhrase(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, queryToken{kind: tokenPhrase, text: text, pos: i, end: end})
+			i = end
+		default:
+			tok, err := lexWord(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			i = tok.end
+		}
+	}
+	return tokens, nil
+}
+
+// lexWord reads a bare word or a field:value term starting at start.
+func lexWord(query string, start int) (queryToken, error) {
+	i := start
+	for i < len(query) && !isQueryDelimiter(query[i]) && query[i] != ':' {
+		i++
+	}
+
+	if i >= len(query) || query[i] != ':' {
+		return queryToken{kind: tokenWord, text: query[start:i], pos: start, end: i}, nil
+	}
+
+	field := strings.ToLower(query[start:i])
+	i++ // skip ':'
+
+	if i < len(query) && query[i] == '"' {
+		text, end, err := lexPhrase(query, i)
+		if err != nil {
+			return queryToken{}, err
+		}
+		return queryToken{kind: tokenWord, field: field, text: text, pos: start, end: end}, nil
+	}
+
+	valueStart := i
+	for i < len(query) && !isQueryDelimiter(query[i]) {
+		i++
+	}
+	return queryToken{kind: tokenWord, field: field, text: query[valueStart:i], pos: start, end: i}, nil
+}
+
+// lexPhrase reads a double-quoted phrase starting at the opening quote.
+// Backslash escapes the next character.
+func lexPhrase(query string, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if i+1 < len(query) {
+				i++
+				b.WriteByte(query[i])
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(query[i])
+		}
+	}
+	p := &queryParser{query: query}
+	return "", 0, p.errorAt(queryToken{pos: start, end: len(query)}, "unterminated quoted phrase")
+}
+
+// isQueryDelimiter reports whether c ends a bare word.
+func isQueryDelimiter(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')' || c == '"'
+}
+
+// queryParser is a recursive descent parser over query tokens:
+//
+//	or      = and { "OR" and }
+//	and     = unary { [ "AND" ] unary }
+//	unary   = ( "NOT" | "-" ) unary | primary
+//	primary = "(" or ")" | term
+type queryParser struct {
+	query  string
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+// isKeyword reports whether tok is the bare operator keyword kw. Operators
+// must be upper case so that "and", "or" and "not" can still be searched for.
+func isKeyword(tok *queryToken, kw string) bool {
+	return tok != nil && tok.kind == tokenWord && tok.field == "" && tok.text == kw
+}
+
+func (p *queryParser) parseOr() (*imap.SearchCriteria, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for isKeyword(p.peek(), "OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &imap.SearchCriteria{Or: [][2]imap.SearchCriteria{{*left, *right}}}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (*imap.SearchCriteria, error) {
+	criteria, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok == nil || tok.kind == tokenRParen || isKeyword(tok, "OR") {
+			return criteria, nil
+		}
+		if isKeyword(tok, "AND") {
+			p.pos++
+		}
+		next, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		// SearchCriteria.And drops an existing SMALLER when the other side
+		// has none, so keep it ourselves.
+		smaller := criteria.Smaller
+		criteria.And(next)
+		if next.Smaller == 0 {
+			criteria.Smaller = smaller
+		}
+	}
+}
+
+func (p *queryParser) parseUnary() (*imap.SearchCriteria, error) {
+	tok := p.peek()
+	if tok != nil && (tok.kind == tokenMinus || isKeyword(tok, "NOT")) {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &imap.SearchCriteria{Not: []imap.SearchCriteria{*operand}}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (*imap.SearchCriteria, error) {
+	tok := p.peek()
+	if tok == nil {
+		return nil, p.errorAt(queryToken{pos: len(p.query), end: len(p.query)}, "unexpected end of query")
+	}
+
+	switch {
+	case tok.kind == tokenLParen:
+		p.pos++
+		criteria, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next == nil || next.kind != tokenRParen {
+			return nil, p.errorAt(*tok, "unmatched \"(\"")
+		}
+		p.pos++
+		return criteria, nil
+	case tok.kind == tokenRParen:
+		return nil, p.errorAt(*tok, "unexpected \")\"")
+	case isKeyword(tok, "OR"), isKeyword(tok, "AND"):
+		return nil, p.errorAt(*tok, fmt.Sprintf("%s needs a term on both sides", tok.text))
+	}
+
+	p.pos++
+	return p.compileTerm(tok)
+}
+
+// compileTerm converts a single word, phrase or field:value term.
+func (p *queryParser) compileTerm(tok *queryToken) (*imap.SearchCriteria, error) {
+	if tok.field == "" {
+		return &imap.SearchCriteria{
+			Or: [][2]imap.SearchCriteria{{
+				{Header: []imap.SearchCriteriaHeaderField{{Key: "Subject", Value: tok.text}}},
+				{Header: []imap.SearchCriteriaHeaderField{{Key: "From", Value: tok.text}}},
+			}},
+		}, nil
+	}
+
+	if tok.text == "" {
+		return nil, p.errorAt(*tok, fmt.Sprintf("missing value for %s:", tok.field))
+	}
+
+	switch tok.field {
+	case "from", "to", "cc", "subject":
+		key := strings.ToUpper(tok.field[:1]) + tok.field[1:]
+		return &imap.SearchCriteria{Header: []imap.SearchCriteriaHeaderField{{Key: key, Value: tok.text}}}, nil
+	case "body":
+		return &imap.SearchCriteria{Body: []string{tok.text}}, nil
+	case "before", "after", "on":
+		date, err := time.Parse("2006-01-02", tok.text)
+		if err != nil {
+			return nil, p.errorAt(*tok, fmt.Sprintf("invalid date %q, expected YYYY-MM-DD", tok.text))
+		}
+		switch tok.field {
+		case "before":
+			return &imap.SearchCriteria{Before: date}, nil
+		case "after":
+			return &imap.SearchCriteria{Since: date}, nil
+		default:
+			return &imap.SearchCriteria{Since: date, Before: date.AddDate(0, 0, 1)}, nil
+		}
+	case "larger", "smaller":
+		size, err := parseSize(tok.text)
+		if err != nil {
+			return nil, p.errorAt(*tok, fmt.Sprintf("invalid size %q", tok.text))
+		}
+		if tok.field == "larger" {
+			return &imap.SearchCriteria{Larger: size}, nil
+		}
+		return &imap.SearchCriteria{Smaller: size}, nil
+	case "is":
+		switch strings.ToLower(tok.text) {
+		case "unread":
+			return &imap.SearchCriteria{NotFlag: []imap.Flag{imap.FlagSeen}}, nil
+		case "read":
+			return &imap.SearchCriteria{Flag: []imap.Flag{imap.FlagSeen}}, nil
+		case "flagged", "starred":
+			return &imap.SearchCriteria{Flag: []imap.Flag{imap.FlagFlagged}}, nil
+		case "answered", "replied":
+			return &imap.SearchCriteria{Flag: []imap.Flag{imap.FlagAnswered}}, nil
+		}
+		return nil, p.errorAt(*tok, fmt.Sprintf("unknown is:%s, expected unread, read, flagged or answered", tok.text))
+	case "has":
+		if strings.EqualFold(tok.text, "attachment") {
+			return hasAttachmentCriteria(), nil
+		}
+		return nil, p.errorAt(*tok, fmt.Sprintf("unknown has:%s, expected attachment", tok.text))
+	case "uid":
+		set, err := ParseUIDSet(tok.text)
+		if err != nil {
+			return nil, p.errorAt(*tok, err.Error())
+		}
+		return &imap.SearchCriteria{UID: []imap.UIDSet{set}}, nil
+	}
+
+	return nil, p.errorAt(*tok, fmt.Sprintf("unknown field %q", tok.field+":"))
+}
+
+// attachmentKey is the header key of the placeholder for has:attachment.
+// No query can produce it otherwise.
+const attachmentKey = "X-Yoy-Has-Attachment"
+
+// hasAttachmentCriteria matches messages with attachments. IMAP cannot
+// search by body structure, so this is a placeholder that resolveCriteria
+// replaces by the UIDs of such messages before searching.
+func hasAttachmentCriteria() *imap.SearchCriteria {
+	return &imap.SearchCriteria{Header: []imap.SearchCriteriaHeaderField{{Key: attachmentKey}}}
+}
+
+// hasAttachmentTerm reports whether criteria use hasAttachmentCriteria.
+func hasAttachmentTerm(criteria *imap.SearchCriteria) bool {
+	for _, f := range criteria.Header {
+		if f.Key == attachmentKey {
+			return true
+		}
+	}
+	for i := range criteria.Not {
+		if hasAttachmentTerm(&criteria.Not[i]) {
+			return true
+		}
+	}
+	for i := range criteria.Or {
+		if hasAttachmentTerm(&criteria.Or[i][0]) || hasAttachmentTerm(&criteria.Or[i][1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCriteria prepares criteria for a search in the selected folder:
+// has:attachment is replaced by the UIDs of the multipart messages there
+// whose body structure has an attachment part.
+func (ic *IMAPClient) resolveCriteria(criteria *imap.SearchCriteria) (*imap.SearchCriteria, error) {
+	if !hasAttachmentTerm(criteria) {
+		return criteria, nil
+	}
+
+	multipart := &imap.SearchCriteria{Header: []imap.SearchCriteriaHeaderField{{Key: "Content-Type", Value: "multipart/"}}}
+	searchData, err := ic.client.UIDSearch(multipart, nil).Wait()
+	if err != nil {
+		return nil, yoyerrors.FromIMAPError(err)
+	}
+	var withAttachments []imap.UID
+	if uids := searchData.AllUIDs(); len(uids) > 0 {
+		fetchOptions := &imap.FetchOptions{
+			UID:           true,
+			BodyStructure: &imap.FetchItemBodyStructure{Extended: true},
+		}
+		msgs, err := ic.client.Fetch(imap.UIDSetNum(uids...), fetchOptions).Collect()
+		if err != nil {
+			return nil, yoyerrors.FromIMAPError(err)
+		}
+		for _, msg := range msgs {
+			if msg.BodyStructure != nil && len(attachmentsFromBodyStructure(msg.BodyStructure)) > 0 {
+				withAttachments = append(withAttachments, msg.UID)
+			}
+		}
+	}
+	return replaceAttachmentTerm(criteria, withAttachments), nil
+}
+
+// replaceAttachmentTerm returns a copy of criteria with has:attachment
+// replaced by a search for uids.
+func replaceAttachmentTerm(criteria *imap.SearchCriteria, uids []imap.UID) *imap.SearchCriteria {
+	out := *criteria
+	out.Header, out.Not, out.Or = nil, nil, nil
+	for _, not := range criteria.Not {
+		out.Not = append(out.Not, *replaceAttachmentTerm(&not, uids))
+	}
+	for _, or := range criteria.Or {
+		out.Or = append(out.Or, [2]imap.SearchCriteria{
+			*replaceAttachmentTerm(&or[0], uids),
+			*replaceAttachmentTerm(&or[1], uids),
+		})
+	}
+	for _, f := range criteria.Header {
+		switch {
+		case f.Key != attachmentKey:
+			out.Header = append(out.Header, f)
+		case len(uids) == 0:
+			// NOT ALL: no message has an attachment.
+			out.Not = append(out.Not, imap.SearchCriteria{})
+		default:
+			out.UID = append(slices.Clone(out.UID), imap.UIDSetNum(uids...))
+		}
+	}
+	return &out
+}
+
+// parseSize parses a size such as "500", "10K" or "2MB".
+func parseSize(s string) (int64, error) {
+	upper := strings.TrimSuffix(strings.ToUpper(s), "B")
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(upper, "K"):
+		multiplier = 1024
+	case strings.HasSuffix(upper, "M"):
+		multiplier = 1024 * 1024
+	case strings.HasSuffix(upper, "G"):
+		multiplier = 1024 * 1024 * 1024
+	}
+	if multiplier > 1 {
+		upper = upper[:len(upper)-1]
+	}
+
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// errorAt returns an invalid input error that shows the query with the
+// offending token underlined.
+func (p *queryParser) errorAt(tok queryToken, msg string) error {
+	indent := utf8.RuneCountInString(p.query[:tok.pos])
+	width := utf8.RuneCountInString(p.query[tok.pos:tok.end])
+	if width == 0 {
+		width = 1
+	}
+
+	pointer := fmt.Sprintf("%s\n  %s\n  %s%s", msg, p.query, strings.Repeat(" ", indent), strings.Repeat("^", width))
+	return yoyerrors.New("invalid search query: "+pointer, yoyerrors.ExitInvalidInput).
+		WithHint("Run 'yoy mail search --help' for the query syntax.")
+}
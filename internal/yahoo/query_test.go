@@ -0,0 +1,195 @@
+package yahoo
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+func TestReplaceAttachmentTerm(t *testing.T) {
+	criteria, err := ParseQuery("from:alice (-has:attachment OR subject:x)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasAttachmentTerm(criteria) {
+		t.Fatal("hasAttachmentTerm() = false, want true")
+	}
+
+	got := replaceAttachmentTerm(criteria, []imap.UID{3, 5})
+	if hasAttachmentTerm(got) {
+		t.Error("placeholder left after replaceAttachmentTerm")
+	}
+	if !hasAttachmentTerm(criteria) {
+		t.Error("replaceAttachmentTerm changed its argument")
+	}
+	not := got.Or[0][0].Not[0]
+	if len(not.UID) != 1 || not.UID[0].String() != "3,5" {
+		t.Errorf("has:attachment became %+v, want UID 3,5", not)
+	}
+
+	// Without any matches, has:attachment matches nothing: NOT ALL.
+	got = replaceAttachmentTerm(hasAttachmentCriteria(), nil)
+	if len(got.Header) != 0 || len(got.UID) != 0 || len(got.Not) != 1 {
+		t.Errorf("has:attachment without matches became %+v, want NOT ALL", got)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	header := func(key, value string) imap.SearchCriteria {
+		return imap.SearchCriteria{Header: []imap.SearchCriteriaHeaderField{{Key: key, Value: value}}}
+	}
+	or := func(a, b imap.SearchCriteria) imap.SearchCriteria {
+		return imap.SearchCriteria{Or: [][2]imap.SearchCriteria{{a, b}}}
+	}
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+
+	tests := []struct {
+		query string
+		want  imap.SearchCriteria
+	}{
+		{"from:alice", header("From", "alice")},
+		{`subject:"q3 report"`, header("Subject", "q3 report")},
+		{"hello", or(header("Subject", "hello"), header("From", "hello"))},
+		{`"a \"b\""`, or(header("Subject", `a "b"`), header("From", `a "b"`))},
+		{"body:invoice", imap.SearchCriteria{Body: []string{"invoice"}}},
+		{"is:unread", imap.SearchCriteria{NotFlag: []imap.Flag{imap.FlagSeen}}},
+		{"is:starred", imap.SearchCriteria{Flag: []imap.Flag{imap.FlagFlagged}}},
+		{"larger:10K", imap.SearchCriteria{Larger: 10 * 1024}},
+		{"smaller:2MB", imap.SearchCriteria{Smaller: 2 * 1024 * 1024}},
+		{"before:2024-01-31", imap.SearchCriteria{Before: day("2024-01-31")}},
+		{"after:2024-01-01", imap.SearchCriteria{Since: day("2024-01-01")}},
+		{"on:2024-01-01", imap.SearchCriteria{Since: day("2024-01-01"), Before: day("2024-01-02")}},
+		{"uid:5:*", imap.SearchCriteria{UID: []imap.UIDSet{{imap.UIDRange{Start: 5, Stop: 0}}}}},
+		{
+			"from:a to:b",
+			imap.SearchCriteria{Header: []imap.SearchCriteriaHeaderField{{Key: "From", Value: "a"}, {Key: "To", Value: "b"}}},
+		},
+		{"from:a AND to:b", imap.SearchCriteria{Header: []imap.SearchCriteriaHeaderField{{Key: "From", Value: "a"}, {Key: "To", Value: "b"}}}},
+		{"from:a OR from:b", or(header("From", "a"), header("From", "b"))},
+		{"-from:a", imap.SearchCriteria{Not: []imap.SearchCriteria{header("From", "a")}}},
+		{"NOT NOT from:a", imap.SearchCriteria{Not: []imap.SearchCriteria{{Not: []imap.SearchCriteria{header("From", "a")}}}}},
+		{
+			// OR binds looser than AND.
+			"from:a to:b OR cc:c",
+			or(imap.SearchCriteria{Header: []imap.SearchCriteriaHeaderField{{Key: "From", Value: "a"}, {Key: "To", Value: "b"}}}, header("Cc", "c")),
+		},
+		{
+			"from:a (to:b OR cc:c)",
+			imap.SearchCriteria{
+				Header: []imap.SearchCriteriaHeaderField{{Key: "From", Value: "a"}},
+				Or:     [][2]imap.SearchCriteria{{header("To", "b"), header("Cc", "c")}},
+			},
+		},
+		{
+			// Lower-case operators are search words.
+			"or",
+			or(header("Subject", "or"), header("From", "or")),
+		},
+		{
+			// A dash inside a word is not negation.
+			"e-mail",
+			or(header("Subject", "e-mail"), header("From", "e-mail")),
+		},
+		{
+			"smaller:1M larger:10",
+			imap.SearchCriteria{Larger: 10, Smaller: 1024 * 1024},
+		},
+		{
+			"larger:10 smaller:1M",
+			imap.SearchCriteria{Larger: 10, Smaller: 1024 * 1024},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("ParseQuery(%q) =\n%+v\nwant\n%+v", tt.query, *got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	// Each error shows the query with the offending part underlined.
+	tests := []struct {
+		query, msg, caret string
+	}{
+		{"", "empty search query", ""},
+		{"from:a (b", `unmatched "("`, "       ^"},
+		{"a)", `unmatched ")"`, " ^"},
+		{"OR x", "OR needs a term on both sides", "^^"},
+		{"x OR", "unexpected end of query", "    ^"},
+		{`from:a "abc`, "unterminated quoted phrase", "       ^^^^"},
+		{"is:foo", "unknown is:foo", "^^^^^^"},
+		{"has:pdf", "unknown has:pdf", "^^^^^^^"},
+		{"after:2024-13-01", `invalid date "2024-13-01"`, "^^^^^^^^^^^^^^^^"},
+		{"larger:10Q", `invalid size "10Q"`, "^^^^^^^^^^"},
+		{"zz:1", `unknown field "zz:"`, "^^^^"},
+		{"from:", "missing value for from:", "^^^^^"},
+		{"uid:0", `invalid UID "0"`, "^^^^^"},
+		// Positions count characters, not bytes.
+		{"café is:nope", "unknown is:nope", "     ^^^^^^^"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		if err == nil {
+			t.Errorf("ParseQuery(%q) succeeded, want error", tt.query)
+			continue
+		}
+		if yoyerrors.ExitCodeFrom(err) != yoyerrors.ExitInvalidInput {
+			t.Errorf("ParseQuery(%q) exit code = %d, want %d", tt.query, yoyerrors.ExitCodeFrom(err), yoyerrors.ExitInvalidInput)
+		}
+		lines := strings.Split(err.Error(), "\n")
+		if !strings.Contains(lines[0], tt.msg) {
+			t.Errorf("ParseQuery(%q) error = %q, want it to contain %q", tt.query, lines[0], tt.msg)
+		}
+		if tt.caret == "" {
+			continue
+		}
+		if len(lines) != 3 || lines[1] != "  "+tt.query || lines[2] != "  "+tt.caret {
+			t.Errorf("ParseQuery(%q) error =\n%s\nwant the query and caret line %q", tt.query, err, "  "+tt.caret)
+		}
+	}
+}
+
+func TestParseUIDSet(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"101", "101"},
+		{"101,105,200:250", "101,105,200:250"},
+		{" 3 , 4 ", "3:4"},
+		{"*", "*"},
+		{"5:*", "5:*"},
+		{"*:5", "5:*"},
+		{"250:200", "200:250"},
+	}
+	for _, tt := range tests {
+		set, err := ParseUIDSet(tt.in)
+		if err != nil {
+			t.Errorf("ParseUIDSet(%q): %v", tt.in, err)
+			continue
+		}
+		if set.String() != tt.want {
+			t.Errorf("ParseUIDSet(%q) = %q, want %q", tt.in, set.String(), tt.want)
+		}
+	}
+
+	for _, in := range []string{"", " ", "0", "a", "1,,2", "1:", "-3", "4294967296"} {
+		if _, err := ParseUIDSet(in); err == nil {
+			t.Errorf("ParseUIDSet(%q) succeeded, want error", in)
+		}
+	}
+}
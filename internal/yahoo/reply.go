@@ -0,0 +1,95 @@
+package yahoo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatMessageID returns a message ID in angle brackets, as used in
+// Message-ID, In-Reply-To and References headers.
+func FormatMessageID(id string) string {
+	return "<" + normalizeMessageID(id) + ">"
+}
+
+// ReplyReferences returns the References of a reply to m: the references of
+// m (or its In-Reply-To when it has none) followed by m's own Message-ID.
+func ReplyReferences(m *Message) []string {
+	ids := m.References
+	if len(ids) == 0 && m.InReplyTo != "" {
+		ids = []string{m.InReplyTo}
+	}
+	if m.MessageID != "" {
+		ids = append(ids[:len(ids):len(ids)], m.MessageID)
+	}
+
+	seen := map[string]bool{}
+	var refs []string
+	for _, id := range ids {
+		id = normalizeMessageID(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		refs = append(refs, FormatMessageID(id))
+	}
+	return refs
+}
+
+// ReplyRecipients returns the recipients of a reply to m sent by self. The
+// reply goes to m's Reply-To, or else its sender; replying to all copies its
+// other recipients. Addresses are compared case-insensitively and self is
+// left out. A reply to one's own message goes to its original recipients.
+func ReplyRecipients(m *Message, self string, all bool) (to, cc []string) {
+	seen := map[string]bool{strings.ToLower(self): true}
+	add := func(list []string, addrs []Address) []string {
+		for _, a := range addrs {
+			key := strings.ToLower(a.Address)
+			if a.Address == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			list = append(list, a.Address)
+		}
+		return list
+	}
+
+	primary := m.ReplyTo
+	if len(primary) == 0 {
+		primary = []Address{m.From}
+	}
+	to = add(to, primary)
+	if len(to) == 0 {
+		to = add(to, m.To)
+	}
+
+	if all {
+		cc = add(cc, m.To)
+		cc = add(cc, m.Cc)
+	}
+	return to, cc
+}
+
+// QuoteMessage returns the text of m as quoted in a reply: an attribution
+// line followed by the body with each line prefixed by ">".
+func QuoteMessage(m *Message) string {
+	from := m.From.Address
+	if m.From.Name != "" {
+		from = fmt.Sprintf("%s <%s>", m.From.Name, m.From.Address)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "On %s, %s wrote:\n", m.Date.Format("Mon, Jan 2, 2006 at 3:04 PM"), from)
+
+	body := strings.TrimRight(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n")
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case line == "":
+			b.WriteString(">\n")
+		case strings.HasPrefix(line, ">"):
+			b.WriteString(">" + line + "\n")
+		default:
+			b.WriteString("> " + line + "\n")
+		}
+	}
+	return b.String()
+}
@@ -1,31 +1,114 @@
 package yahoo
 
 import (
+	"bufio"
+	"bytes"
 	"crypto/tls"
 	"fmt"
 	"net"
 	"net/smtp"
+	"time"
+
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
 
 	"github.com/Softorize/yoy/internal/auth"
 	"github.com/Softorize/yoy/internal/config"
 	yoyerrors "github.com/Softorize/yoy/internal/errors"
 )
 
-// SendMail sends an email via Yahoo's SMTP server.
-func SendMail(email string, opts *SendOptions) error {
-	creds, err := auth.LoadCredentials()
-	if err != nil {
-		return yoyerrors.Wrap("loading credentials", err, yoyerrors.ExitAuth).
-			WithHint("Run 'yoy auth login' to authenticate.")
-	}
-
+// SendMail sends an email via Yahoo's SMTP server and returns the message
+// exactly as it was sent.
+func SendMail(email string, opts *SendOptions) ([]byte, error) {
 	// Compose the message.
 	if opts.From == "" {
 		opts.From = email
 	}
 	msgBytes, err := ComposeMessage(opts)
 	if err != nil {
-		return yoyerrors.Wrap("composing message", err, yoyerrors.ExitGeneral)
+		return nil, yoyerrors.Wrap("composing message", err, yoyerrors.ExitGeneral)
+	}
+
+	allRecipients := make([]string, 0, len(opts.To)+len(opts.Cc)+len(opts.Bcc))
+	allRecipients = append(allRecipients, opts.To...)
+	allRecipients = append(allRecipients, opts.Cc...)
+	allRecipients = append(allRecipients, opts.Bcc...)
+
+	if err := deliver(email, opts.From, allRecipients, msgBytes); err != nil {
+		return nil, err
+	}
+	return msgBytes, nil
+}
+
+// SendRaw sends a composed message, such as a draft, keeping its header and
+// body as they are, and returns the message exactly as it was sent. A
+// message without a From field is sent from email.
+func SendRaw(email string, raw []byte) ([]byte, error) {
+	from, recipients, msgBytes, err := prepareRaw(raw, email)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := deliver(email, from, recipients, msgBytes); err != nil {
+		return nil, err
+	}
+	return msgBytes, nil
+}
+
+// prepareRaw returns the sender and recipients of a composed message, taken
+// from its From, To, Cc and Bcc fields, and the message to send: the same
+// with the Bcc field removed, the Date set to now and the From field set to
+// sender if it has none.
+func prepareRaw(raw []byte, sender string) (from string, recipients []string, msg []byte, err error) {
+	br := bufio.NewReader(bytes.NewReader(raw))
+	th, err := textproto.ReadHeader(br)
+	if err != nil {
+		return "", nil, nil, yoyerrors.Wrap("reading message header", err, yoyerrors.ExitGeneral)
+	}
+	h := mail.Header{Header: message.Header{Header: th}}
+
+	for _, key := range []string{"To", "Cc", "Bcc"} {
+		addrs, err := h.AddressList(key)
+		if err != nil {
+			return "", nil, nil, yoyerrors.Wrap("reading "+key+" addresses", err, yoyerrors.ExitGeneral)
+		}
+		for _, a := range addrs {
+			recipients = append(recipients, a.Address)
+		}
+	}
+	if len(recipients) == 0 {
+		return "", nil, nil, yoyerrors.New("message has no recipients", yoyerrors.ExitInvalidInput)
+	}
+	if addrs, err := h.AddressList("From"); err == nil && len(addrs) > 0 {
+		from = addrs[0].Address
+	} else {
+		from = sender
+		if !h.Has("From") {
+			h.SetAddressList("From", []*mail.Address{{Address: sender}})
+		}
+	}
+
+	h.Del("Bcc")
+	h.SetDate(time.Now())
+
+	var buf bytes.Buffer
+	if err := textproto.WriteHeader(&buf, h.Header.Header); err != nil {
+		return "", nil, nil, yoyerrors.Wrap("composing message", err, yoyerrors.ExitGeneral)
+	}
+	if _, err := buf.ReadFrom(br); err != nil {
+		return "", nil, nil, yoyerrors.Wrap("composing message", err, yoyerrors.ExitGeneral)
+	}
+	return from, recipients, buf.Bytes(), nil
+}
+
+// deliver hands msgBytes to Yahoo's SMTP server for the given recipients,
+// authenticating as email.
+func deliver(email, from string, recipients []string, msgBytes []byte) error {
+	creds, err := auth.LoadCredentials()
+	if err != nil {
+		return yoyerrors.Wrap("loading credentials", err, yoyerrors.ExitAuth).
+			WithHint("Run 'yoy auth login' to authenticate.")
 	}
 
 	// Connect via TLS (port 465).
@@ -54,17 +137,12 @@ func SendMail(email string, opts *SendOptions) error {
 	}
 
 	// Set sender.
-	if err := c.Mail(opts.From); err != nil {
+	if err := c.Mail(from); err != nil {
 		return yoyerrors.FromSMTPError(fmt.Errorf("MAIL FROM: %w", err))
 	}
 
 	// Set recipients.
-	allRecipients := make([]string, 0, len(opts.To)+len(opts.Cc)+len(opts.Bcc))
-	allRecipients = append(allRecipients, opts.To...)
-	allRecipients = append(allRecipients, opts.Cc...)
-	allRecipients = append(allRecipients, opts.Bcc...)
-
-	for _, rcpt := range allRecipients {
+	for _, rcpt := range recipients {
 		if err := c.Rcpt(rcpt); err != nil {
 			return yoyerrors.FromSMTPError(fmt.Errorf("RCPT TO %s: %w", rcpt, err))
 		}
@@ -85,5 +163,8 @@ func SendMail(email string, opts *SendOptions) error {
 		return yoyerrors.FromSMTPError(fmt.Errorf("closing data: %w", err))
 	}
 
-	return c.Quit()
+	// The message was accepted with the end of DATA; a failed QUIT doesn't
+	// undo that.
+	_ = c.Quit()
+	return nil
 }
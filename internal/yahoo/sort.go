@@ -0,0 +1,237 @@
+package yahoo
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+// SortKey names a message listing order.
+type SortKey string
+
+// Supported sort keys.
+const (
+	SortDate    SortKey = "date"    // Date header
+	SortArrival SortKey = "arrival" // time the server received the message
+	SortFrom    SortKey = "from"    // sender's mailbox, the address before the @
+	SortSubject SortKey = "subject" // subject without Re:/Fwd: prefixes
+	SortSize    SortKey = "size"    // message size
+)
+
+// SortOrder selects how a listing is ordered. The zero value keeps the
+// default newest-first order.
+//
+// Dates and sizes sort largest first and addresses and subjects sort A to Z;
+// Reverse flips either.
+type SortOrder struct {
+	Key     SortKey
+	Reverse bool
+}
+
+// imapSortKeys maps sort keys to IMAP SORT keys (RFC 5256).
+var imapSortKeys = map[SortKey]imapclient.SortKey{
+	SortDate:    imapclient.SortKeyDate,
+	SortArrival: imapclient.SortKeyArrival,
+	SortFrom:    imapclient.SortKeyFrom,
+	SortSubject: imapclient.SortKeySubject,
+	SortSize:    imapclient.SortKeySize,
+}
+
+// ParseSortKey validates a sort key name.
+func ParseSortKey(s string) (SortKey, error) {
+	key := SortKey(strings.ToLower(s))
+	if _, ok := imapSortKeys[key]; !ok {
+		return "", yoyerrors.New(fmt.Sprintf("invalid sort key %q", s), yoyerrors.ExitInvalidInput).
+			WithHint("Use one of: date, arrival, from, subject, size.")
+	}
+	return key, nil
+}
+
+// descending reports whether the order puts the largest value first.
+func (o SortOrder) descending() bool {
+	desc := o.Key == SortDate || o.Key == SortArrival || o.Key == SortSize
+	return desc != o.Reverse
+}
+
+// sortEntry holds the values a message can be sorted by.
+type sortEntry struct {
+	folder  string
+	uid     imap.UID
+	date    time.Time
+	arrival time.Time
+	from    string
+	subject string
+	size    int64
+}
+
+// sortedSearch returns the UIDs matching criteria in the selected folder in
+// the requested order, using the server's SORT extension when it is
+// advertised. Without a sort key they are newest first.
+func (ic *IMAPClient) sortedSearch(criteria *imap.SearchCriteria, order SortOrder) ([]imap.UID, error) {
+	criteria, err := ic.resolveCriteria(criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	if order.Key == "" {
+		searchData, err := ic.client.UIDSearch(criteria, nil).Wait()
+		if err != nil {
+			return nil, yoyerrors.FromIMAPError(err)
+		}
+		uids := searchData.AllUIDs()
+		slices.Sort(uids)
+		slices.Reverse(uids)
+		return uids, nil
+	}
+
+	if ic.client.Caps().Has(imap.CapSort) {
+		nums, err := ic.client.UIDSort(&imapclient.SortOptions{
+			SearchCriteria: criteria,
+			SortCriteria:   []imapclient.SortCriterion{{Key: imapSortKeys[order.Key], Reverse: order.descending()}},
+		}).Wait()
+		if err != nil {
+			return nil, yoyerrors.FromIMAPError(err)
+		}
+		uids := make([]imap.UID, len(nums))
+		for i, n := range nums {
+			uids[i] = imap.UID(n)
+		}
+		return uids, nil
+	}
+
+	searchData, err := ic.client.UIDSearch(criteria, nil).Wait()
+	if err != nil {
+		return nil, yoyerrors.FromIMAPError(err)
+	}
+
+	entries, err := ic.fetchSortEntries("", searchData.AllUIDs())
+	if err != nil {
+		return nil, err
+	}
+	sortEntries(entries, order)
+
+	uids := make([]imap.UID, len(entries))
+	for i, e := range entries {
+		uids[i] = e.uid
+	}
+	return uids, nil
+}
+
+// fetchSortEntries fetches the sort values of messages in the selected
+// folder.
+func (ic *IMAPClient) fetchSortEntries(folder string, uids []imap.UID) ([]sortEntry, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	fetchOptions := &imap.FetchOptions{
+		UID:          true,
+		Envelope:     true,
+		InternalDate: true,
+		RFC822Size:   true,
+	}
+
+	msgs, err := ic.client.Fetch(imap.UIDSetNum(uids...), fetchOptions).Collect()
+	if err != nil {
+		return nil, yoyerrors.FromIMAPError(err)
+	}
+
+	entries := make([]sortEntry, 0, len(msgs))
+	for _, msg := range msgs {
+		entries = append(entries, newSortEntry(folder, msg))
+	}
+	return entries, nil
+}
+
+// newSortEntry returns the sort values of a fetched message.
+func newSortEntry(folder string, msg *imapclient.FetchMessageBuffer) sortEntry {
+	e := sortEntry{
+		folder:  folder,
+		uid:     msg.UID,
+		arrival: msg.InternalDate,
+		size:    msg.RFC822Size,
+	}
+	if env := msg.Envelope; env != nil {
+		e.date = env.Date
+		e.subject = baseSubject(env.Subject)
+		if len(env.From) > 0 {
+			// SORT FROM compares the addr-mailbox (RFC 5256).
+			e.from = strings.ToLower(env.From[0].Mailbox)
+		}
+	}
+	return e
+}
+
+// sortEntries sorts client-side, the same way the SORT extension would. Ties
+// keep UID order.
+func sortEntries(entries []sortEntry, order SortOrder) {
+	compare := func(a, b *sortEntry) int {
+		switch order.Key {
+		case SortDate:
+			return a.date.Compare(b.date)
+		case SortArrival:
+			return a.arrival.Compare(b.arrival)
+		case SortFrom:
+			return strings.Compare(a.from, b.from)
+		case SortSubject:
+			return strings.Compare(a.subject, b.subject)
+		case SortSize:
+			switch {
+			case a.size < b.size:
+				return -1
+			case a.size > b.size:
+				return 1
+			}
+		}
+		return 0
+	}
+
+	desc := order.descending()
+	sort.SliceStable(entries, func(i, j int) bool {
+		c := compare(&entries[i], &entries[j])
+		if c == 0 {
+			return entries[i].uid < entries[j].uid
+		}
+		return (c < 0) != desc
+	})
+}
+
+// orderByUIDs returns messages in the order of uids.
+func orderByUIDs(messages []Message, uids []imap.UID) []Message {
+	byUID := make(map[uint32]Message, len(messages))
+	for _, m := range messages {
+		byUID[m.UID] = m
+	}
+	ordered := make([]Message, 0, len(messages))
+	for _, uid := range uids {
+		if m, ok := byUID[uint32(uid)]; ok {
+			ordered = append(ordered, m)
+		}
+	}
+	return ordered
+}
+
+// subjectPrefix matches reply and forward prefixes such as "Re:", "Fwd:" and
+// "[list] ", which RFC 5256 strips to get the base subject.
+var subjectPrefix = regexp.MustCompile(`(?i)^\s*((re|fwd?|aw|sv|wg)\s*(\[\d+\])?\s*:|\[[^\]]*\])\s*`)
+
+// baseSubject returns the subject used for sorting and threading.
+func baseSubject(subject string) string {
+	for {
+		stripped := subjectPrefix.ReplaceAllString(subject, "")
+		if stripped == subject {
+			break
+		}
+		subject = stripped
+	}
+	subject = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(subject), "(fwd)"))
+	return strings.ToLower(strings.Join(strings.Fields(subject), " "))
+}
@@ -0,0 +1,47 @@
+package yahoo
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+)
+
+func TestSortEntriesFrom(t *testing.T) {
+	// By full address "ann.lee@z" sorts before "ann@a"; SORT FROM compares
+	// the mailboxes, "ann" and "ann.lee".
+	from := map[imap.UID]imap.Address{
+		1: {Mailbox: "Zoe", Host: "a.example"},
+		2: {Mailbox: "ann.lee", Host: "z.example"},
+		3: {Mailbox: "ann", Host: "a.example"},
+		4: {Mailbox: "bob", Host: "a.example"},
+		5: {Mailbox: "ann", Host: "b.example"},
+	}
+	var entries []sortEntry
+	for uid := range imap.UID(len(from)) {
+		msg := &imapclient.FetchMessageBuffer{
+			UID:      uid + 1,
+			Envelope: &imap.Envelope{From: []imap.Address{from[uid+1]}},
+		}
+		entries = append(entries, newSortEntry("INBOX", msg))
+	}
+
+	tests := []struct {
+		order SortOrder
+		want  []imap.UID
+	}{
+		{SortOrder{Key: SortFrom}, []imap.UID{3, 5, 2, 4, 1}},
+		{SortOrder{Key: SortFrom, Reverse: true}, []imap.UID{1, 4, 2, 3, 5}},
+	}
+	for _, tt := range tests {
+		sortEntries(entries, tt.order)
+		var got []imap.UID
+		for _, e := range entries {
+			got = append(got, e.uid)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("sortEntries(%+v) = %v, want %v", tt.order, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package yahoo
+
+import (
+	"iter"
+	"slices"
+
+	"github.com/emersion/go-imap/v2"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+// streamBatchSize is how many messages are fetched per command when
+// streaming. It bounds memory use and the delay before the first message.
+const streamBatchSize = 100
+
+// StreamMessages lists messages like ListMessages, but yields them in order
+// as they are fetched instead of returning them all at the end. An error
+// ends the stream. The returned function gives the cursor of the next page,
+// as MessagePage.NextCursor, once the stream has been read to the end.
+func (ic *IMAPClient) StreamMessages(folder string, opts *ListOptions) (iter.Seq2[Message, error], func() uint32) {
+	var next uint32
+	seq := func(yield func(Message, error) bool) {
+		next = 0
+		mbox, err := ic.client.Select(folder, nil).Wait()
+		if err != nil {
+			yield(Message{}, yoyerrors.FromIMAPError(err))
+			return
+		}
+		if mbox.NumMessages == 0 {
+			return
+		}
+
+		if opts.BeforeUID == 0 && opts.AfterUID == 0 && !opts.filtered() && opts.Sort.Key == "" {
+			start, end, ok := opts.seqRange(mbox.NumMessages)
+			if !ok {
+				return
+			}
+			// Fetch backwards from the end of the mailbox.
+			for hi := end; hi >= start; hi -= streamBatchSize {
+				lo := start
+				if hi-start >= streamBatchSize {
+					lo = hi - streamBatchSize + 1
+				}
+				var seqSet imap.SeqSet
+				seqSet.AddRange(lo, hi)
+				messages, err := ic.fetchMessages(seqSet)
+				if err == nil {
+					sortByUIDDesc(messages)
+				}
+				if !ic.yieldBatch(yield, messages, opts.Preview, err) {
+					return
+				}
+				if lo == start {
+					if start > 1 && len(messages) > 0 {
+						next = messages[len(messages)-1].UID
+					}
+					return
+				}
+			}
+			return
+		}
+
+		uids, more, err := ic.searchUIDs(opts)
+		if err != nil {
+			yield(Message{}, err)
+			return
+		}
+		var cursor uint32
+		if more && opts.Sort.Key == "" {
+			cursor = uint32(uids[len(uids)-1])
+		}
+		if opts.Sort.Key == "" && opts.AfterUID > 0 {
+			// A page walking forward is still shown newest first.
+			slices.Reverse(uids)
+		}
+		if ic.streamUIDs(yield, uids, opts.Preview) {
+			next = cursor
+		}
+	}
+	return seq, func() uint32 { return next }
+}
+
+// StreamSearch searches a folder like SearchMessages, yielding messages in
+// order as they are fetched.
+func (ic *IMAPClient) StreamSearch(folder, query string, order SortOrder) iter.Seq2[Message, error] {
+	return func(yield func(Message, error) bool) {
+		criteria, err := ParseQuery(query)
+		if err != nil {
+			yield(Message{}, err)
+			return
+		}
+		ic.streamMatches(folder, criteria, order)(yield)
+	}
+}
+
+// streamMatches yields the messages in folder matching criteria, in the
+// order given, newest first by default.
+func (ic *IMAPClient) streamMatches(folder string, criteria *imap.SearchCriteria, order SortOrder) iter.Seq2[Message, error] {
+	return func(yield func(Message, error) bool) {
+		if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
+			yield(Message{}, yoyerrors.FromIMAPError(err))
+			return
+		}
+		uids, err := ic.sortedSearch(criteria, order)
+		if err != nil {
+			yield(Message{}, err)
+			return
+		}
+		ic.streamUIDs(yield, uids, false)
+	}
+}
+
+// streamUIDs fetches messages in the selected folder in batches and yields
+// them in the order of uids. It reports whether all of them were yielded.
+func (ic *IMAPClient) streamUIDs(yield func(Message, error) bool, uids []imap.UID, preview bool) bool {
+	for batch := range slices.Chunk(uids, streamBatchSize) {
+		messages, err := ic.fetchMessages(imap.UIDSetNum(batch...))
+		if err == nil {
+			messages = orderByUIDs(messages, batch)
+		}
+		if !ic.yieldBatch(yield, messages, preview, err) {
+			return false
+		}
+	}
+	return true
+}
+
+// yieldBatch adds previews to a fetched batch if asked and yields its
+// messages, or yields err. It reports whether the stream should go on.
+func (ic *IMAPClient) yieldBatch(yield func(Message, error) bool, messages []Message, preview bool, err error) bool {
+	if err == nil && preview {
+		err = ic.addPreviews(messages)
+	}
+	if err != nil {
+		yield(Message{}, err)
+		return false
+	}
+	for _, m := range messages {
+		if !yield(m, nil) {
+			return false
+		}
+	}
+	return true
+}
+
+// collectMessages gathers a stream into a slice.
+func collectMessages(seq iter.Seq2[Message, error]) ([]Message, error) {
+	var messages []Message
+	for m, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
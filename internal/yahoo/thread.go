@@ -0,0 +1,540 @@
+package yahoo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+	"github.com/emersion/go-message"
+	"github.com/emersion/go-message/mail"
+	"github.com/emersion/go-message/textproto"
+
+	yoyerrors "github.com/Softorize/yoy/internal/errors"
+)
+
+// threadNode is a message in a conversation tree, followed by its replies. A
+// node with UID 0 stands for a message that is referenced but not in the
+// folder.
+type threadNode struct {
+	uid      imap.UID
+	children []*threadNode
+}
+
+// walk calls fn for every message in the tree, parents before replies, with
+// its depth. Missing messages are skipped and their replies moved up.
+func (n *threadNode) walk(depth int, fn func(uid imap.UID, depth int)) {
+	if n.uid == 0 {
+		depth--
+	} else {
+		fn(n.uid, depth)
+	}
+	for _, c := range n.children {
+		c.walk(depth+1, fn)
+	}
+}
+
+// uids returns the UIDs of all messages in the tree.
+func (n *threadNode) uids() []imap.UID {
+	var uids []imap.UID
+	n.walk(0, func(uid imap.UID, _ int) { uids = append(uids, uid) })
+	return uids
+}
+
+// ThreadMessages returns the conversation a message belongs to, within its
+// folder. Each message is followed by its replies and has Depth set to its
+// nesting level. Only the messages found by threadCriteria are threaded, not
+// the whole folder.
+func (ic *IMAPClient) ThreadMessages(folder string, uid uint32) ([]Message, error) {
+	if _, err := ic.client.Select(folder, nil).Wait(); err != nil {
+		return nil, yoyerrors.FromIMAPError(err)
+	}
+
+	headers, err := ic.fetchThreadHeaders([]imap.UID{imap.UID(uid)})
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) == 0 {
+		return nil, yoyerrors.New(fmt.Sprintf("message %d not found in %s", uid, folder), yoyerrors.ExitNotFound)
+	}
+
+	roots, err := ic.threads(threadCriteria(&headers[0]))
+	if err != nil {
+		return nil, err
+	}
+
+	var root *threadNode
+	for _, r := range roots {
+		if slices.Contains(r.uids(), imap.UID(uid)) {
+			root = r
+			break
+		}
+	}
+	if root == nil {
+		return nil, yoyerrors.New(fmt.Sprintf("message %d not found in %s", uid, folder), yoyerrors.ExitNotFound)
+	}
+
+	var uids []imap.UID
+	depths := map[uint32]int{}
+	root.walk(0, func(uid imap.UID, depth int) {
+		uids = append(uids, uid)
+		depths[uint32(uid)] = depth
+	})
+
+	messages, err := ic.fetchMessages(imap.UIDSetNum(uids...))
+	if err != nil {
+		return nil, err
+	}
+	messages = orderByUIDs(messages, uids)
+	for i := range messages {
+		messages[i].Depth = depths[messages[i].UID]
+	}
+	return messages, nil
+}
+
+// ListThreads lists one page of conversations in a folder, most recently
+// active first. Each conversation is shown as its newest message, with
+// ThreadCount set to the number of its messages that match the filters.
+func (ic *IMAPClient) ListThreads(folder string, opts *ListOptions) (*MessagePage, error) {
+	mbox, err := ic.client.Select(folder, nil).Wait()
+	if err != nil {
+		return nil, yoyerrors.FromIMAPError(err)
+	}
+	if mbox.NumMessages == 0 {
+		return &MessagePage{}, nil
+	}
+
+	criteria := &imap.SearchCriteria{}
+	if opts.BeforeUID > 0 || opts.AfterUID > 0 || opts.filtered() {
+		all := *opts
+		all.Limit, all.Offset, all.Sort = 0, 0, SortOrder{}
+		uids, _, err := ic.searchUIDs(&all)
+		if err != nil {
+			return nil, err
+		}
+		if len(uids) == 0 {
+			return &MessagePage{}, nil
+		}
+		criteria.UID = []imap.UIDSet{imap.UIDSetNum(uids...)}
+	}
+
+	roots, err := ic.threads(criteria)
+	if err != nil {
+		return nil, err
+	}
+
+	type conversation struct {
+		newest imap.UID
+		count  int
+	}
+	conversations := make([]conversation, 0, len(roots))
+	for _, r := range roots {
+		uids := r.uids()
+		if len(uids) == 0 {
+			continue
+		}
+		conversations = append(conversations, conversation{newest: slices.Max(uids), count: len(uids)})
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].newest > conversations[j].newest
+	})
+
+	if opts.Offset >= uint32(len(conversations)) {
+		return &MessagePage{}, nil
+	}
+	conversations = conversations[opts.Offset:]
+	if opts.Limit > 0 && uint32(len(conversations)) > opts.Limit {
+		conversations = conversations[:opts.Limit]
+	}
+
+	uids := make([]imap.UID, len(conversations))
+	counts := make(map[uint32]int, len(conversations))
+	for i, c := range conversations {
+		uids[i] = c.newest
+		counts[uint32(c.newest)] = c.count
+	}
+
+	messages, err := ic.fetchMessages(imap.UIDSetNum(uids...))
+	if err != nil {
+		return nil, err
+	}
+	messages = orderByUIDs(messages, uids)
+	for i := range messages {
+		messages[i].ThreadCount = counts[messages[i].UID]
+	}
+
+	if opts.Preview {
+		if err := ic.addPreviews(messages); err != nil {
+			return nil, err
+		}
+	}
+	return &MessagePage{Messages: messages}, nil
+}
+
+// threadCriteria matches the messages that may share a conversation with h:
+// h itself, the first message it refers to, messages whose References or
+// In-Reply-To name either of them, and messages with the same base subject,
+// which replies whose parent is missing are joined by.
+func threadCriteria(h *threadHeader) *imap.SearchCriteria {
+	alternatives := []imap.SearchCriteria{{UID: []imap.UIDSet{imap.UIDSetNum(h.uid)}}}
+
+	ids := []string{h.messageID}
+	if parents := h.parents(); len(parents) > 0 {
+		ids = append(ids, parents[0])
+	}
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		for _, key := range []string{"Message-ID", "References", "In-Reply-To"} {
+			alternatives = append(alternatives, imap.SearchCriteria{
+				Header: []imap.SearchCriteriaHeaderField{{Key: key, Value: "<" + id + ">"}},
+			})
+		}
+	}
+	if base := baseSubject(h.subject); base != "" {
+		alternatives = append(alternatives, imap.SearchCriteria{
+			Header: []imap.SearchCriteriaHeaderField{{Key: "Subject", Value: base}},
+		})
+	}
+	return anyOf(alternatives)
+}
+
+// anyOf combines search criteria with OR.
+func anyOf(alternatives []imap.SearchCriteria) *imap.SearchCriteria {
+	if len(alternatives) == 1 {
+		return &alternatives[0]
+	}
+	return &imap.SearchCriteria{
+		Or: [][2]imap.SearchCriteria{{alternatives[0], *anyOf(alternatives[1:])}},
+	}
+}
+
+// threads returns the conversation trees of the messages in the selected
+// folder that match criteria. The server threads them if it supports
+// THREAD=REFERENCES (RFC 5256); otherwise their headers are fetched and
+// threaded here.
+func (ic *IMAPClient) threads(criteria *imap.SearchCriteria) ([]*threadNode, error) {
+	criteria, err := ic.resolveCriteria(criteria)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(ic.client.Caps().ThreadAlgorithms(), imap.ThreadReferences) {
+		data, err := ic.client.UIDThread(&imapclient.ThreadOptions{
+			Algorithm:      imap.ThreadReferences,
+			SearchCriteria: criteria,
+		}).Wait()
+		if err != nil {
+			return nil, yoyerrors.FromIMAPError(err)
+		}
+		roots := make([]*threadNode, len(data))
+		for i := range data {
+			roots[i] = threadFromData(&data[i])
+		}
+		return roots, nil
+	}
+
+	searchData, err := ic.client.UIDSearch(criteria, nil).Wait()
+	if err != nil {
+		return nil, yoyerrors.FromIMAPError(err)
+	}
+	headers, err := ic.fetchThreadHeaders(searchData.AllUIDs())
+	if err != nil {
+		return nil, err
+	}
+	return threadHeaders(headers), nil
+}
+
+// threadFromData converts a THREAD response. Its chain is a line of replies;
+// the subthreads are replies to the last message of the chain.
+func threadFromData(data *imapclient.ThreadData) *threadNode {
+	root := &threadNode{}
+	node := root
+	for i, num := range data.Chain {
+		if i == 0 {
+			root.uid = imap.UID(num)
+			continue
+		}
+		child := &threadNode{uid: imap.UID(num)}
+		node.children = append(node.children, child)
+		node = child
+	}
+	for i := range data.SubThreads {
+		node.children = append(node.children, threadFromData(&data.SubThreads[i]))
+	}
+	return root
+}
+
+// replyPrefix matches the subject of a reply or forward, after any list tags.
+var replyPrefix = regexp.MustCompile(`(?i)^\s*(\[[^\]]*\]\s*)*(re|fwd?|aw|sv|wg)\s*(\[\d+\])?\s*:`)
+
+// threadHeader holds the headers a message is threaded by.
+type threadHeader struct {
+	uid        imap.UID
+	messageID  string
+	references []string
+	inReplyTo  string
+	subject    string
+	date       time.Time
+}
+
+// parents returns the message IDs h refers to, oldest first. Without
+// References, In-Reply-To names the parent.
+func (h *threadHeader) parents() []string {
+	if len(h.references) == 0 && h.inReplyTo != "" {
+		return []string{h.inReplyTo}
+	}
+	return h.references
+}
+
+// fetchThreadHeaders fetches the threading headers of messages in the
+// selected folder.
+func (ic *IMAPClient) fetchThreadHeaders(uids []imap.UID) ([]threadHeader, error) {
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	fetchOptions := &imap.FetchOptions{
+		UID:      true,
+		Envelope: true,
+		BodySection: []*imap.FetchItemBodySection{{
+			Specifier:    imap.PartSpecifierHeader,
+			HeaderFields: []string{"References"},
+			Peek:         true,
+		}},
+	}
+
+	fetchCmd := ic.client.Fetch(imap.UIDSetNum(uids...), fetchOptions)
+	var headers []threadHeader
+	for {
+		msg := fetchCmd.Next()
+		if msg == nil {
+			break
+		}
+
+		var h threadHeader
+		for {
+			item := msg.Next()
+			if item == nil {
+				break
+			}
+			switch data := item.(type) {
+			case imapclient.FetchItemDataUID:
+				h.uid = data.UID
+			case imapclient.FetchItemDataEnvelope:
+				if env := data.Envelope; env != nil {
+					h.messageID = normalizeMessageID(env.MessageID)
+					h.subject = env.Subject
+					h.date = env.Date
+					if len(env.InReplyTo) > 0 {
+						h.inReplyTo = normalizeMessageID(env.InReplyTo[0])
+					}
+				}
+			case imapclient.FetchItemDataBodySection:
+				raw, _ := io.ReadAll(data.Literal)
+				h.references = parseReferences(raw)
+			}
+		}
+		headers = append(headers, h)
+	}
+	if err := fetchCmd.Close(); err != nil {
+		return nil, yoyerrors.FromIMAPError(err)
+	}
+	return headers, nil
+}
+
+// parseReferences returns the message IDs of a References header section.
+func parseReferences(raw []byte) []string {
+	th, err := textproto.ReadHeader(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return nil
+	}
+	h := mail.Header{Header: message.Header{Header: th}}
+	ids, err := h.MsgIDList("References")
+	if err != nil {
+		// Be lenient with malformed headers.
+		ids = strings.Fields(h.Get("References"))
+	}
+	for i, id := range ids {
+		ids[i] = normalizeMessageID(id)
+	}
+	return ids
+}
+
+// normalizeMessageID strips the angle brackets and surrounding space of a
+// message ID.
+func normalizeMessageID(id string) string {
+	return strings.Trim(strings.TrimSpace(id), "<>")
+}
+
+// threadHeaders threads messages by their headers, following the REFERENCES
+// algorithm of RFC 5256 (itself based on Jamie Zawinski's): messages are
+// linked through their References, and conversations whose first message
+// was lost are joined by subject.
+func threadHeaders(headers []threadHeader) []*threadNode {
+	type container struct {
+		header   *threadHeader
+		parent   *container
+		children []*container
+	}
+
+	byID := map[string]*container{}
+	var containers []*container
+	get := func(id string) *container {
+		if c, ok := byID[id]; ok {
+			return c
+		}
+		c := &container{}
+		byID[id] = c
+		containers = append(containers, c)
+		return c
+	}
+	// isAncestor reports whether a is b or one of its parents.
+	isAncestor := func(a, b *container) bool {
+		for ; b != nil; b = b.parent {
+			if a == b {
+				return true
+			}
+		}
+		return false
+	}
+	unlink := func(child *container) {
+		if child.parent != nil {
+			siblings := child.parent.children
+			child.parent.children = slices.DeleteFunc(siblings, func(c *container) bool { return c == child })
+			child.parent = nil
+		}
+	}
+	setParent := func(child, parent *container) {
+		unlink(child)
+		child.parent = parent
+		parent.children = append(parent.children, child)
+	}
+
+	for i := range headers {
+		h := &headers[i]
+
+		c := byID[h.messageID]
+		switch {
+		case h.messageID == "" || c != nil && c.header != nil:
+			// No or duplicate Message-ID.
+			c = &container{}
+			containers = append(containers, c)
+		case c == nil:
+			c = get(h.messageID)
+		}
+		c.header = h
+
+		// Link the references into a chain, keeping links already made.
+		var prev *container
+		for _, ref := range h.parents() {
+			if ref == "" {
+				continue
+			}
+			r := get(ref)
+			if prev != nil && r.parent == nil && !isAncestor(r, prev) {
+				setParent(r, prev)
+			}
+			prev = r
+		}
+
+		// The last reference is the parent of the message itself, replacing
+		// any parent guessed from other messages' references.
+		switch {
+		case prev == nil:
+			unlink(c)
+		case !isAncestor(c, prev):
+			setParent(c, prev)
+		}
+	}
+
+	// date is the date of a message, or of the first reply to a missing one.
+	var date func(c *container) time.Time
+	date = func(c *container) time.Time {
+		if c.header != nil {
+			return c.header.date
+		}
+		var first time.Time
+		for _, child := range c.children {
+			if d := date(child); first.IsZero() || d.Before(first) {
+				first = d
+			}
+		}
+		return first
+	}
+	// subject is the subject of a message, or of its first reply.
+	var subject func(c *container) string
+	subject = func(c *container) string {
+		if c.header != nil {
+			return c.header.subject
+		}
+		for _, child := range c.children {
+			if s := subject(child); s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+	// build converts a container to nodes, replacing missing messages by
+	// their replies and ordering replies by date.
+	var build func(c *container) []*threadNode
+	build = func(c *container) []*threadNode {
+		children := slices.Clone(c.children)
+		sort.SliceStable(children, func(i, j int) bool { return date(children[i]).Before(date(children[j])) })
+
+		var nodes []*threadNode
+		for _, child := range children {
+			nodes = append(nodes, build(child)...)
+		}
+		if c.header == nil {
+			return nodes
+		}
+		return []*threadNode{{uid: c.header.uid, children: nodes}}
+	}
+
+	var roots []*container
+	for _, c := range containers {
+		if c.parent == nil && (c.header != nil || len(c.children) > 0) {
+			roots = append(roots, c)
+		}
+	}
+	sort.SliceStable(roots, func(i, j int) bool { return date(roots[i]).Before(date(roots[j])) })
+
+	// Join replies whose parent is gone to the conversation with the same
+	// subject.
+	var nodes []*threadNode
+	bySubject := map[string]*threadNode{}
+	for _, r := range roots {
+		built := build(r)
+		if len(built) == 0 {
+			continue
+		}
+		node := built[0]
+		if len(built) > 1 {
+			node = &threadNode{children: built}
+		}
+
+		s := subject(r)
+		base := baseSubject(s)
+		if base == "" {
+			nodes = append(nodes, node)
+			continue
+		}
+		if first, ok := bySubject[base]; ok && replyPrefix.MatchString(s) {
+			first.children = append(first.children, node)
+			continue
+		}
+		if _, ok := bySubject[base]; !ok {
+			bySubject[base] = node
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
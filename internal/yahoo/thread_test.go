@@ -0,0 +1,226 @@
+package yahoo
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+)
+
+// formatThreads renders trees as "1(2 3(4)) 5": each UID followed by its
+// replies in parentheses, with "?" for a missing message.
+func formatThreads(nodes []*threadNode) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		s := "?"
+		if n.uid != 0 {
+			s = fmt.Sprint(n.uid)
+		}
+		if len(n.children) > 0 {
+			s += "(" + formatThreads(n.children) + ")"
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, " ")
+}
+
+// header returns a threadHeader for message uid, dated uid minutes after a
+// fixed time so that UIDs give the date order.
+func header(uid uint32, id, subject string, refs ...string) threadHeader {
+	return threadHeader{
+		uid:        imap.UID(uid),
+		messageID:  id,
+		references: refs,
+		subject:    subject,
+		date:       time.Date(2026, 1, 1, 0, int(uid), 0, 0, time.UTC),
+	}
+}
+
+func TestThreadHeaders(t *testing.T) {
+	inReplyTo := header(2, "b", "Re: hello")
+	inReplyTo.inReplyTo = "a"
+
+	tests := []struct {
+		name    string
+		headers []threadHeader
+		want    string
+	}{
+		{
+			name: "chain of references",
+			headers: []threadHeader{
+				header(1, "a", "hello"),
+				header(2, "b", "Re: hello", "a"),
+				header(3, "c", "Re: hello", "a", "b"),
+				header(4, "d", "Re: hello", "a"),
+			},
+			want: "1(2(3) 4)",
+		},
+		{
+			name: "replies arrive before the original",
+			headers: []threadHeader{
+				header(3, "c", "Re: hello", "a", "b"),
+				header(2, "b", "Re: hello", "a"),
+				header(1, "a", "hello"),
+			},
+			want: "1(2(3))",
+		},
+		{
+			name: "in-reply-to without references",
+			headers: []threadHeader{
+				header(1, "a", "hello"),
+				inReplyTo,
+			},
+			want: "1(2)",
+		},
+		{
+			name: "missing parent is skipped",
+			headers: []threadHeader{
+				header(2, "b", "Re: hello", "a"),
+				header(3, "c", "Re: hello", "a"),
+			},
+			want: "?(2 3)",
+		},
+		{
+			name: "missing parent with one reply",
+			headers: []threadHeader{
+				header(2, "b", "Re: hello", "gone", "a"),
+				header(3, "c", "Re: hello", "gone", "a", "b"),
+			},
+			want: "2(3)",
+		},
+		{
+			name: "duplicate message ids",
+			headers: []threadHeader{
+				header(1, "a", "hello"),
+				header(2, "a", "hello"),
+				header(3, "b", "Re: hello", "a"),
+			},
+			want: "1(3) 2",
+		},
+		{
+			name: "self reference",
+			headers: []threadHeader{
+				header(1, "a", "hello", "a"),
+				header(2, "b", "Re: hello", "a"),
+			},
+			want: "1(2)",
+		},
+		{
+			name: "reference cycle",
+			headers: []threadHeader{
+				header(1, "a", "one", "b"),
+				header(2, "b", "two", "a"),
+			},
+			want: "2(1)",
+		},
+		{
+			name: "joined by subject",
+			headers: []threadHeader{
+				header(1, "a", "Weekly report"),
+				header(2, "b", "Re: Weekly report", "lost"),
+				header(3, "c", "Fwd: [list] RE: weekly  report", "lost2"),
+			},
+			want: "1(2 3)",
+		},
+		{
+			name: "same subject without reply prefix stays apart",
+			headers: []threadHeader{
+				header(1, "a", "Weekly report"),
+				header(2, "b", "Weekly report"),
+			},
+			want: "1 2",
+		},
+		{
+			name: "no message ids",
+			headers: []threadHeader{
+				header(1, "", "one"),
+				header(2, "", "two"),
+			},
+			want: "1 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatThreads(threadHeaders(tt.headers)); got != tt.want {
+				t.Errorf("threadHeaders() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreadFromData(t *testing.T) {
+	// (1 2 (3 4)(5)): 2 replies to 1, and 3 and 5 to 2.
+	data := &imapclient.ThreadData{
+		Chain: []uint32{1, 2},
+		SubThreads: []imapclient.ThreadData{
+			{Chain: []uint32{3, 4}},
+			{Chain: []uint32{5}},
+		},
+	}
+	if got, want := formatThreads([]*threadNode{threadFromData(data)}), "1(2(3(4) 5))"; got != want {
+		t.Errorf("threadFromData() = %q, want %q", got, want)
+	}
+
+	// ((1)(2)): a missing root with two replies.
+	data = &imapclient.ThreadData{
+		SubThreads: []imapclient.ThreadData{{Chain: []uint32{1}}, {Chain: []uint32{2}}},
+	}
+	if got, want := formatThreads([]*threadNode{threadFromData(data)}), "?(1 2)"; got != want {
+		t.Errorf("threadFromData() = %q, want %q", got, want)
+	}
+}
+
+func TestThreadNodeWalk(t *testing.T) {
+	// 1 has replies 2 (with reply 3) and a missing message whose reply 4
+	// moves up to 1's level of replies.
+	root := &threadNode{uid: 1, children: []*threadNode{
+		{uid: 2, children: []*threadNode{{uid: 3}}},
+		{children: []*threadNode{{uid: 4}}},
+	}}
+
+	var got []string
+	root.walk(0, func(uid imap.UID, depth int) {
+		got = append(got, fmt.Sprintf("%d:%d", uid, depth))
+	})
+	if want := "1:0 2:1 3:2 4:1"; strings.Join(got, " ") != want {
+		t.Errorf("walk() = %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+func TestThreadCriteria(t *testing.T) {
+	h := header(7, "c", "Re: Hello", "a", "b")
+	got := map[string]bool{}
+	for c := threadCriteria(&h); ; c = &c.Or[0][1] {
+		alt := c
+		if len(c.Or) > 0 {
+			alt = &c.Or[0][0]
+		}
+		for _, f := range alt.Header {
+			got[f.Key+" "+f.Value] = true
+		}
+		if len(alt.UID) > 0 {
+			got["UID "+alt.UID[0].String()] = true
+		}
+		if len(c.Or) == 0 {
+			break
+		}
+	}
+
+	for _, want := range []string{
+		"UID 7",
+		"Message-ID <c>", "References <c>", "In-Reply-To <c>",
+		"Message-ID <a>", "References <a>", "In-Reply-To <a>",
+		"Subject hello",
+	} {
+		if !got[want] {
+			t.Errorf("threadCriteria() lacks %q, has %v", want, got)
+		}
+	}
+	if len(got) != 8 {
+		t.Errorf("threadCriteria() has %d alternatives, want 8: %v", len(got), got)
+	}
+}
@@ -1,6 +1,11 @@
 package yahoo
 
-import "time"
+import (
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-message"
+)
 
 // Address represents an email address.
 type Address struct {
@@ -10,14 +15,18 @@ type Address struct {
 
 // Attachment represents an email attachment.
 type Attachment struct {
+	Index       int    `json:"index"`
+	Part        string `json:"part,omitempty"`
 	Filename    string `json:"filename"`
 	ContentType string `json:"content_type"`
 	Size        int    `json:"size"`
+	Encoding    string `json:"-"`
 }
 
 // Message represents an email message.
 type Message struct {
 	UID         uint32       `json:"uid"`
+	Folder      string       `json:"folder,omitempty"`
 	MessageID   string       `json:"message_id,omitempty"`
 	Subject     string       `json:"subject"`
 	From        Address      `json:"from"`
@@ -33,6 +42,55 @@ type Message struct {
 	Attachments []Attachment `json:"attachments,omitempty"`
 	InReplyTo   string       `json:"in_reply_to,omitempty"`
 	References  []string     `json:"references,omitempty"`
+
+	Size            int64  `json:"size"`
+	HasAttachments  bool   `json:"has_attachments"`
+	AttachmentCount int    `json:"attachment_count"`
+	Preview         string `json:"preview,omitempty"`
+
+	// Depth is the nesting level of a message in a conversation view, and
+	// ThreadCount the number of messages in a collapsed conversation.
+	Depth       int `json:"depth,omitempty"`
+	ThreadCount int `json:"thread_count,omitempty"`
+
+	// BodyFromHTML is set when the message has no text/plain part and Body
+	// was rendered from HTMLBody.
+	BodyFromHTML bool `json:"-"`
+
+	// bodyStructure is kept from a listing fetch to locate the preview part.
+	bodyStructure imap.BodyStructure
+}
+
+// MessagePage is one page of a message listing.
+type MessagePage struct {
+	Messages []Message `json:"messages"`
+	// NextCursor is the UID to pass back with the same cursor flag to get
+	// the next page. It is zero on the last page.
+	NextCursor uint32 `json:"next_cursor,omitempty"`
+}
+
+// ListOptions controls which page of a folder ListMessages returns and how
+// the folder is filtered. Zero values are ignored.
+type ListOptions struct {
+	Limit     uint32 // zero means no limit
+	Offset    uint32 // messages to skip, counted from the cursor
+	BeforeUID uint32 // only messages with a lower UID
+	AfterUID  uint32 // only messages with a higher UID
+
+	Unread        bool
+	Flagged       bool
+	Answered      bool
+	HasAttachment bool
+	From          string    // substring of the From header
+	Since         time.Time // received at or after
+	Until         time.Time // received before
+
+	// Preview fetches the start of each message's text for Message.Preview.
+	Preview bool
+
+	// Sort replaces the newest-first UID order. Cursors still filter by UID
+	// but no NextCursor is returned; page with Offset instead.
+	Sort SortOrder
 }
 
 // Folder represents a mail folder.
@@ -44,12 +102,33 @@ type Folder struct {
 
 // SendOptions holds options for sending an email.
 type SendOptions struct {
-	From    string
-	To      []string
-	Cc      []string
-	Bcc     []string
-	Subject string
-	Body    string
-	ReplyTo string
-	Headers map[string]string
+	From        string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	Subject     string
+	Body        string
+	HTMLBody    string // sent with Body as a multipart/alternative if set
+	ReplyTo     string
+	MessageID   string // generated on the sender's domain if empty
+	Headers     map[string]string
+	Attachments []string // file paths
+	Parts       []Part   // added after Attachments
+	Forward     *ForwardedMessage
+}
+
+// Part is a MIME part copied into a composed message, such as an attachment
+// kept from a draft. Body is decoded and re-encoded according to Header.
+type Part struct {
+	Header message.Header
+	Body   []byte
+}
+
+// ForwardedMessage holds the original message included in a forward.
+type ForwardedMessage struct {
+	// Raw is the complete RFC 5322 source of the original message.
+	Raw []byte
+	// AsAttachment wraps Raw in a message/rfc822 part instead of copying
+	// its MIME parts into the new message.
+	AsAttachment bool
 }
@@ -0,0 +1,57 @@
+package yahoo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-imap/v2"
+)
+
+// ParseUIDSet parses a comma-separated list of UIDs and UID ranges such as
+// "101,105,200:250". "*" stands for the highest UID in the folder.
+func ParseUIDSet(s string) (imap.UIDSet, error) {
+	var set imap.UIDSet
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("empty UID set")
+	}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		start, stop, isRange := strings.Cut(item, ":")
+
+		first, err := parseUID(start)
+		if err != nil {
+			return nil, err
+		}
+		if !isRange {
+			if first == 0 {
+				set.AddRange(0, 0)
+			} else {
+				set.AddNum(first)
+			}
+			continue
+		}
+
+		last, err := parseUID(stop)
+		if err != nil {
+			return nil, err
+		}
+		set.AddRange(first, last)
+	}
+
+	return set, nil
+}
+
+// parseUID parses a single UID. "*" is returned as 0, which go-imap uses for
+// the largest UID in the mailbox.
+func parseUID(s string) (imap.UID, error) {
+	if s == "*" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid UID %q", s)
+	}
+	return imap.UID(n), nil
+}